@@ -0,0 +1,185 @@
+package keg
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rwxrob/json"
+	"github.com/rwxrob/term"
+)
+
+// KegEntry is a DexEntry from a specific named keg (see AllDex).
+type KegEntry struct {
+	Keg string
+	*DexEntry
+}
+
+// KegDex is a collection of KegEntry structs from multiple kegs.
+type KegDex []KegEntry
+
+// AllDex reads the dex of every keg in kegs at the same time, passes
+// each to filter (if not nil) to select the entries wanted, and merges
+// the results into a single KegDex sorted by time of last change (most
+// recent first). Kegs with a dex that cannot be read, or for which
+// filter returns an error, are logged and skipped so that one missing
+// keg does not prevent seeing the others.
+func AllDex(kegs []MapEntry, filter func(k MapEntry, dex Dex) (Dex, error)) KegDex {
+	results := make([]Dex, len(kegs))
+	var wg sync.WaitGroup
+	for i, k := range kegs {
+		wg.Add(1)
+		go func(i int, k MapEntry) {
+			defer wg.Done()
+			dex, err := ReadDex(k.Dir)
+			if err == nil && filter != nil {
+				var d Dex
+				d, err = filter(k, *dex)
+				dex = &d
+			}
+			if err != nil {
+				log.Printf("%v: %v", k.Name, err)
+				return
+			}
+			results[i] = *dex
+		}(i, k)
+	}
+	wg.Wait()
+
+	all := KegDex{}
+	for i, dex := range results {
+		for _, e := range dex {
+			all = append(all, KegEntry{Keg: kegs[i].Name, DexEntry: e})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].U.After(all[j].U)
+	})
+	return all
+}
+
+// TitleFilter returns a filter for AllDex that selects only entries
+// with titles matching the regular expression (see Dex.WithTitleTextExp).
+func TitleFilter(re *regexp.Regexp) func(MapEntry, Dex) (Dex, error) {
+	return func(_ MapEntry, dex Dex) (Dex, error) {
+		return dex.WithTitleTextExp(re), nil
+	}
+}
+
+// GrepFilter returns a filter for AllDex that selects only entries with
+// a README.md file containing a match for the regular expression.
+func GrepFilter(re *regexp.Regexp) func(MapEntry, Dex) (Dex, error) {
+	return func(k MapEntry, dex Dex) (Dex, error) {
+		found := Dex{}
+		for _, e := range dex {
+			buf, err := os.ReadFile(filepath.Join(k.Dir, e.ID(), `README.md`))
+			if err != nil {
+				continue
+			}
+			if re.Match(buf) {
+				found = append(found, e)
+			}
+		}
+		return found, nil
+	}
+}
+
+// LinksFilter returns a filter for AllDex that selects only entries
+// with a link to the node with the given id of the keg at kegpath named
+// name (see LinksMap): a regular link (../ID) from within the same keg
+// and a cross-keg link (keg:NAME/ID) from any other. The nodes of kegs
+// without a dex/links file yet are read instead.
+func LinksFilter(kegpath, name string, id int) func(MapEntry, Dex) (Dex, error) {
+	return func(k MapEntry, dex Dex) (Dex, error) {
+		links, err := ReadLinks(k.Dir)
+		if err != nil {
+			links = indexLinks(k.Dir, dex, nil) // no dex/links yet
+		}
+		target := Link{Keg: name, N: id}
+		if sameDir(k.Dir, kegpath) {
+			target.Keg = ""
+		}
+		found := Dex{}
+		for _, n := range links.To(target) {
+			if e := dex.Lookup(n); e != nil {
+				found = append(found, e)
+			}
+		}
+		return found, nil
+	}
+}
+
+// AsIncludes renders the KegDex as a KEGML include list with cross-keg
+// links (see DexEntry.AsKegInclude).
+func (d KegDex) AsIncludes() string {
+	var buf strings.Builder
+	for _, e := range d {
+		buf.WriteString(e.AsKegInclude(e.Keg))
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// MarshalJSON produces a JSON array of the entries in the same form as
+// Dex but with the keg name added as "K" to each.
+func (d KegDex) MarshalJSON() ([]byte, error) {
+	var buf strings.Builder
+	buf.WriteRune('[')
+	for i, e := range d {
+		if i > 0 {
+			buf.WriteString(",\n")
+		}
+		byt, _ := e.DexEntry.MarshalJSON()
+		buf.WriteString(`{"K":"` + json.Escape(e.Keg) + `",`)
+		buf.Write(byt[1:])
+	}
+	buf.WriteRune(']')
+	return []byte(buf.String()), nil
+}
+
+// TSV renders the KegDex as tab-separated values with the keg name as
+// the first column followed by the same columns as Dex.TSV.
+func (d KegDex) TSV() string {
+	var buf strings.Builder
+	for _, e := range d {
+		buf.WriteString(e.Keg + "\t" + e.DexEntry.TSV() + "\n")
+	}
+	return buf.String()
+}
+
+// Pretty returns a string with pretty colors with a column for the keg
+// name before the time, ID, and title of each entry.
+func (d KegDex) Pretty() string {
+	var kwidth, nwidth int
+	for _, e := range d {
+		if len(e.Keg) > kwidth {
+			kwidth = len(e.Keg)
+		}
+		if len(e.ID()) > nwidth {
+			nwidth = len(e.ID())
+		}
+	}
+	var buf strings.Builder
+	for _, e := range d {
+		text := e.T
+		if e.HBeg > 0 || e.HEnd > 0 {
+			text = e.T[0:e.HBeg] + term.Red + e.T[e.HBeg:e.HEnd] +
+				term.White + e.T[e.HEnd:]
+		}
+		fmt.Fprintf(&buf,
+			"%v%-"+strconv.Itoa(kwidth)+"v %v%v %v%-"+strconv.Itoa(nwidth)+"v %v%v%v\n",
+			term.Yellow, e.Keg,
+			term.Black, e.U.Format(`2006-01-02 15:04Z`),
+			term.Green, e.N,
+			term.White, text,
+			term.Reset,
+		)
+	}
+	return buf.String()
+}
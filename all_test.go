@@ -0,0 +1,38 @@
+package keg_test
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleAllDex() {
+	kegs := []keg.MapEntry{
+		{Name: `one`, Dir: `testdata/samplekeg`},
+		{Name: `two`, Dir: `testdata/samplekeg`},
+	}
+	all := keg.AllDex(kegs, keg.TitleFilter(regexp.MustCompile(`for [36]$`)))
+	fmt.Print(all.TSV())
+	fmt.Print(all.AsIncludes())
+
+	// Output:
+	// one	6	2022-11-17 23:34:10Z	Some title for 6
+	// two	6	2022-11-17 23:34:10Z	Some title for 6
+	// one	3	2022-11-17 23:05:08Z	Some title for 3
+	// two	3	2022-11-17 23:05:08Z	Some title for 3
+	// * [Some title for 6](keg:one/6)
+	// * [Some title for 6](keg:two/6)
+	// * [Some title for 3](keg:one/3)
+	// * [Some title for 3](keg:two/3)
+}
+
+func ExampleKegDex_MarshalJSON() {
+	kegs := []keg.MapEntry{{Name: `one`, Dir: `testdata/samplekeg`}}
+	all := keg.AllDex(kegs, keg.TitleFilter(regexp.MustCompile(`for 6$`)))
+	buf, _ := all.MarshalJSON()
+	fmt.Println(string(buf))
+
+	// Output:
+	// [{"K":"one","U":"2022-11-17 23:34:10Z","N":6,"T":"Some title for 6"}]
+}
@@ -0,0 +1,83 @@
+package keg
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rwxrob/fs/file"
+)
+
+// AppendText returns the KEGML text of a node with add appended as a new
+// paragraph (or, if bullet is true, a new bulleted list item) at the end
+// of the body but before any footnotes since KEGML requires that they
+// always come last. A bullet added to a body that already ends with
+// a bulleted list continues that list. Leading and trailing blank lines
+// are removed from add and nothing is changed if it is empty.
+func AppendText(text, add string, bullet bool) string {
+	add = strings.Trim(add, "\n")
+	if strings.TrimSpace(add) == "" {
+		return text
+	}
+	body, foot := splitFootnotes(text)
+	body = strings.TrimRight(body, "\n")
+	if bullet {
+		add = `* ` + strings.ReplaceAll(add, "\n", "\n  ")
+	}
+	sep := "\n\n"
+	if bullet && endsWithBullet(body) {
+		sep = "\n"
+	}
+	body += sep + add + "\n"
+	if foot != "" {
+		body += "\n" + foot
+	}
+	return body
+}
+
+// splitFootnotes splits the text into the body and the footnotes that
+// end it (if any). The footnotes are the trailing lines that begin with
+// "[^" along with any indented or blank lines between or after them.
+func splitFootnotes(text string) (body, foot string) {
+	lines := strings.SplitAfter(text, "\n")
+	start := len(lines)
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		if strings.HasPrefix(line, `[^`) {
+			start = i
+			continue
+		}
+		if strings.TrimSpace(line) != "" &&
+			!strings.HasPrefix(line, ` `) && !strings.HasPrefix(line, "\t") {
+			break
+		}
+	}
+	return strings.Join(lines[:start], ""), strings.Join(lines[start:], "")
+}
+
+// endsWithBullet returns true if the last item of the text (ignoring any
+// indented continuation lines) is a bulleted list item.
+func endsWithBullet(text string) bool {
+	lines := strings.Split(text, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		if strings.HasPrefix(line, `  `) {
+			continue
+		}
+		return strings.HasPrefix(line, `* `) ||
+			strings.HasPrefix(line, `- `) || strings.HasPrefix(line, `+ `)
+	}
+	return false
+}
+
+// AppendNode appends add to the README.md file of the node with the
+// given id in the keg at kegpath (see AppendText).
+func AppendNode(kegpath string, id int, add string, bullet bool) error {
+	path := filepath.Join(kegpath, strconv.Itoa(id), `README.md`)
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return file.Overwrite(path, AppendText(string(buf), add, bullet))
+}
@@ -0,0 +1,33 @@
+package keg_test
+
+import (
+	"fmt"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleAppendText() {
+	text := "# Title\n\nSome body.\n\n* one\n\n[^1]: A footnote\n    continued\n"
+	text = keg.AppendText(text, `two`, true)
+	text = keg.AppendText(text, "A new\nparagraph.\n", false)
+	text = keg.AppendText(text, "  \n", false)
+	fmt.Print(text)
+	fmt.Print(keg.AppendText("# Title\n", `first`, true))
+
+	// Output:
+	// # Title
+	//
+	// Some body.
+	//
+	// * one
+	// * two
+	//
+	// A new
+	// paragraph.
+	//
+	// [^1]: A footnote
+	//     continued
+	// # Title
+	//
+	// * first
+}
@@ -0,0 +1,441 @@
+package keg
+
+import (
+	"fmt"
+	iofs "io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rwxrob/fs"
+	"github.com/rwxrob/fs/file"
+)
+
+// ImageExts are the file name extensions (lowercase) of the files that
+// are added as figures rather than links when attached (see
+// AttachText).
+var ImageExts = []string{`.png`, `.jpg`, `.jpeg`, `.gif`, `.svg`, `.webp`}
+
+// FileLinkExp matches any Markdown link or figure capturing the target
+// (without the optional quoted title). Use LocalFile to tell if it is
+// a file within the node directory.
+var FileLinkExp = regexp.MustCompile(`!?\[[^\]]*\]\(([^\s()]+)(?:\s+"[^"]*")?\)`)
+
+var codeSpanExp = regexp.MustCompile("``[^`]*``|`[^`]*`")
+var unsafeExp = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// SafeName returns a version of the base name of the file path that is
+// safe to use within a node directory and link to without escaping:
+// lowercase letters, digits, dot, underscore, and dash only. Runs of
+// anything else become a single dash. A name that would otherwise be
+// empty (or hidden) becomes "file".
+func SafeName(name string) string {
+	name = strings.ToLower(filepath.Base(name))
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	ext = unsafeExp.ReplaceAllString(ext, ``)
+	stem = strings.Trim(unsafeExp.ReplaceAllString(stem, `-`), `-.`)
+	if stem == "" {
+		stem = `file`
+	}
+	if ext == `.` {
+		ext = ""
+	}
+	return stem + ext
+}
+
+// LocalFile returns the path (relative to the node directory) of the
+// file the link target refers to or false if it is not a local file
+// (a URL, node link, absolute path, or fragment, for example). Any
+// query code (?T) or fragment (#name) is dropped and escapes decoded.
+func LocalFile(target string) (string, bool) {
+	if i := strings.IndexAny(target, `?#`); i >= 0 {
+		target = target[:i]
+	}
+	if target == "" || strings.Contains(target, `:`) ||
+		strings.HasPrefix(target, `/`) {
+		return "", false
+	}
+	if t, err := url.PathUnescape(target); err == nil {
+		target = t
+	}
+	target = path.Clean(target)
+	if target == `.` || target == `..` || strings.HasPrefix(target, `../`) {
+		return "", false
+	}
+	return target, true
+}
+
+// FileLinks returns the local files (see LocalFile) linked to from the
+// KEGML text in the order first found. Links within fenced blocks and
+// code spans are examples, not links, and are ignored.
+func FileLinks(text string) []string {
+	var files []string
+	seen := map[string]bool{}
+	MapLinkTargets(text, func(target string) string {
+		if f, ok := LocalFile(target); ok && !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+		return target
+	})
+	return files
+}
+
+// MapLinkTargets returns the KEGML text with the target of every
+// Markdown link and figure (see FileLinkExp) replaced with what fn
+// returns for it. Links within fenced blocks and code spans are
+// examples and are left as is.
+func MapLinkTargets(text string, fn func(target string) string) string {
+	lines := strings.SplitAfter(text, "\n")
+	var fence string
+	for i, line := range lines {
+		if fence != "" {
+			if endsFence(strings.TrimRight(line, "\n"), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence = fenceOf(line); fence != "" {
+			continue
+		}
+		code := codeSpanExp.FindAllStringIndex(line, -1)
+		var out strings.Builder
+		last := 0
+	link:
+		for _, m := range FileLinkExp.FindAllStringSubmatchIndex(line, -1) {
+			for _, c := range code {
+				if m[0] < c[1] && c[0] < m[1] {
+					continue link
+				}
+			}
+			out.WriteString(line[last:m[2]])
+			out.WriteString(fn(line[m[2]:m[3]]))
+			last = m[3]
+		}
+		out.WriteString(line[last:])
+		lines[i] = out.String()
+	}
+	return strings.Join(lines, "")
+}
+
+// AttachText returns the KEGML text of a node with a figure (for
+// images, see ImageExts) or link for each of the named files appended
+// as its own paragraph (see AppendText). The names must already be
+// safe (see SafeName).
+func AttachText(text string, names ...string) string {
+	for _, name := range names {
+		ext := filepath.Ext(name)
+		link := `[` + name + `](` + name + `)`
+		for _, img := range ImageExts {
+			if ext == img {
+				link = `![` + strings.TrimSuffix(name, ext) + `](` + name + `)`
+				break
+			}
+		}
+		text = AppendText(text, link, false)
+	}
+	return text
+}
+
+// Attach copies the files at paths into the directory of the node with
+// the given id in the keg at kegpath and adds a figure or link to each
+// to its README.md (see AttachText). Each file is given a safe name
+// (see SafeName) with a number added if the node already has a file of
+// that name (photo-2.png, for example). The names given are returned.
+// Nothing is copied unless every path is a regular file.
+func Attach(kegpath string, id int, paths ...string) ([]string, error) {
+	unlock, err := Lock(kegpath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	dir := filepath.Join(kegpath, strconv.Itoa(id))
+	readme := filepath.Join(dir, `README.md`)
+	buf, err := os.ReadFile(readme)
+	if err != nil {
+		return nil, fmt.Errorf(_NodeNotFound, id)
+	}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf(_NotAFile, p)
+		}
+	}
+
+	var names []string
+	for _, p := range paths {
+		name := SafeName(p)
+		ext := filepath.Ext(name)
+		for i := 2; fs.Exists(filepath.Join(dir, name)); i++ {
+			name = strings.TrimSuffix(SafeName(p), ext) + `-` + strconv.Itoa(i) + ext
+		}
+		if err := copyFile(p, filepath.Join(dir, name)); err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+	return names, file.Overwrite(readme, AttachText(string(buf), names...))
+}
+
+// Asset is a local file within a node directory (or linked to from its
+// README.md).
+type Asset struct {
+	N    int    // node ID
+	Name string // path relative to the node directory
+}
+
+// String returns the asset as ID/NAME.
+func (a Asset) String() string { return strconv.Itoa(a.N) + `/` + a.Name }
+
+// Assets checks the local files of every node of the keg at kegpath
+// against the file links of the README.md files (see FileLinks) and
+// returns those that are never linked to (unused) and the links to
+// files that do not exist (missing). Links to the files of other nodes
+// (../ID/NAME) count for those nodes and a missing one is reported for
+// the node with the link (with the link as its name). A link to a
+// directory counts for every file within it. Hidden files and
+// directories are ignored.
+func Assets(kegpath string) (unused, missing []Asset, err error) {
+	dirs, _, _ := NodePaths(kegpath)
+	linked := map[Asset]bool{}
+	for _, d := range dirs {
+		n, err := strconv.Atoi(d.Info.Name())
+		if err != nil {
+			continue
+		}
+		buf, err := os.ReadFile(filepath.Join(d.Path, `README.md`))
+		if err != nil {
+			continue
+		}
+		MapLinkTargets(string(buf), func(target string) string {
+			var a Asset
+			if name, ok := LocalFile(target); ok {
+				a = Asset{n, name}
+			} else if id, name, ok := otherNodeFile(target); ok {
+				a = Asset{id, name}
+			} else {
+				return target
+			}
+			if linked[a] {
+				return target
+			}
+			linked[a] = true
+			f := filepath.Join(kegpath, strconv.Itoa(a.N), filepath.FromSlash(a.Name))
+			switch {
+			case fs.Exists(f):
+			case a.N == n:
+				missing = append(missing, a)
+			default:
+				missing = append(missing, Asset{n, `../` + a.String()})
+			}
+			return target
+		})
+	}
+	for _, d := range dirs {
+		n, err := strconv.Atoi(d.Info.Name())
+		if err != nil {
+			continue
+		}
+		files, err := nodeFiles(d.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+	file:
+		for _, rel := range files {
+			for f := rel; f != `.`; f = path.Dir(f) {
+				if linked[Asset{n, f}] {
+					continue file
+				}
+			}
+			unused = append(unused, Asset{n, rel})
+		}
+	}
+	for _, list := range [][]Asset{unused, missing} {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].N != list[j].N {
+				return list[i].N < list[j].N
+			}
+			return list[i].Name < list[j].Name
+		})
+	}
+	return unused, missing, nil
+}
+
+// otherNodeFile returns the ID of the node and path (relative to its
+// directory) of the file within another node directory that the link
+// target (../ID/NAME) refers to or false if it is not one. As with
+// LocalFile, any query code or fragment is dropped and escapes decoded.
+func otherNodeFile(target string) (int, string, bool) {
+	if i := strings.IndexAny(target, `?#`); i >= 0 {
+		target = target[:i]
+	}
+	if !strings.HasPrefix(target, `../`) || strings.Contains(target, `:`) {
+		return 0, "", false
+	}
+	if t, err := url.PathUnescape(target); err == nil {
+		target = t
+	}
+	id, name, _ := strings.Cut(path.Clean(target[3:]), `/`)
+	n, err := strconv.Atoi(id)
+	if err != nil || strconv.Itoa(n) != id || name == "" {
+		return 0, "", false
+	}
+	return n, name, true
+}
+
+// AssetsConf is the assets section of the keg file setting limits on
+// the files (other than README.md) within node directories that are
+// checked before every publish (see CheckAssets). Problems are only
+// warnings unless Block is true in which case nothing is published
+// until they are fixed.
+//
+//	assets:
+//	  maxsize: 2M                       # bytes or with K, M, or G
+//	  allow: [.png, .jpg, .svg, .pdf]   # extensions (any if empty)
+//	  block: true
+type AssetsConf struct {
+	MaxSize string   `yaml:"maxsize"` // largest file allowed (see ParseSize)
+	Allow   []string `yaml:"allow"`   // extensions allowed
+	Block   bool     `yaml:"block"`   // refuse to publish if any problems
+}
+
+// ParseSize parses a file size in bytes with an optional K, M, or G
+// suffix (each 1024 times the one before, an added B or iB is ignored)
+// such as 500K or 1.5M.
+func ParseSize(s string) (int64, error) {
+	num := strings.TrimSpace(strings.ToUpper(s))
+	num = strings.TrimSuffix(strings.TrimSuffix(num, `B`), `I`)
+	mult := int64(1)
+	if i := strings.IndexAny(num, `KMG`); i >= 0 && i == len(num)-1 {
+		mult = map[byte]int64{'K': 1 << 10, 'M': 1 << 20, 'G': 1 << 30}[num[i]]
+		num = num[:i]
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf(_BadSize, s)
+	}
+	return int64(f * float64(mult)), nil
+}
+
+// sizeString returns the size in bytes in the shortest form ParseSize
+// understands (with one decimal place).
+func sizeString(n int64) string {
+	units := `KMG`
+	f := float64(n)
+	if f < 1024 {
+		return strconv.FormatInt(n, 10)
+	}
+	var u int
+	for f /= 1024; f >= 1024 && u < len(units)-1; u++ {
+		f /= 1024
+	}
+	return strings.TrimSuffix(strconv.FormatFloat(f, 'f', 1, 64), `.0`) + units[u:u+1]
+}
+
+// CheckAssets checks every file (other than README.md and hidden ones)
+// within the node directories of the keg at kegpath against the limits
+// of the conf and returns a description of every problem found (none if
+// conf is nil). Extensions are compared without regard to case. An
+// empty extension in the Allow list allows files without one.
+func CheckAssets(kegpath string, conf *AssetsConf) ([]string, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	var limit int64
+	if conf.MaxSize != "" {
+		var err error
+		if limit, err = ParseSize(conf.MaxSize); err != nil {
+			return nil, err
+		}
+	}
+	allow := map[string]bool{}
+	for _, ext := range conf.Allow {
+		if ext = strings.TrimPrefix(strings.ToLower(ext), `.`); ext != "" {
+			ext = `.` + ext
+		}
+		allow[ext] = true
+	}
+
+	var problems []string
+	dirs, _, _ := NodePaths(kegpath)
+	for _, d := range dirs {
+		files, err := nodeFiles(d.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range files {
+			if ext := strings.ToLower(path.Ext(rel)); len(allow) > 0 && !allow[ext] {
+				if ext == "" {
+					ext = `none`
+				}
+				problems = append(problems, fmt.Sprintf(_AssetNotAllowed, d.Info.Name(), rel, ext))
+			}
+			if limit == 0 {
+				continue
+			}
+			info, err := os.Stat(filepath.Join(d.Path, filepath.FromSlash(rel)))
+			if err != nil {
+				return nil, err
+			}
+			if info.Size() > limit {
+				problems = append(problems, fmt.Sprintf(_AssetTooLarge,
+					d.Info.Name(), rel, sizeString(info.Size()), sizeString(limit)))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// assetsBlocked returns an error if the keg file of the keg at kegpath
+// has an assets section with Block set and CheckAssets finds problems.
+func assetsBlocked(kegpath string) error {
+	info, err := ReadKegInfo(kegpath)
+	if err != nil || info.Assets == nil || !info.Assets.Block {
+		return nil
+	}
+	problems, err := CheckAssets(kegpath, info.Assets)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf(_AssetsBlocked, len(problems))
+	}
+	return nil
+}
+
+// nodeFiles returns the paths (relative to the node directory dir and
+// with forward slashes) of every regular file within it other than
+// README.md and hidden files (or those in hidden directories).
+func nodeFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, e iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(e.Name(), `.`) && p != dir {
+			if e.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !e.Type().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if rel = filepath.ToSlash(rel); rel != `README.md` {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,115 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleSafeName() {
+	fmt.Println(keg.SafeName(`/tmp/My Photo (1).PNG`))
+	fmt.Println(keg.SafeName(`notes.tar.gz`))
+	fmt.Println(keg.SafeName(`.bashrc`))
+	fmt.Println(keg.SafeName(`---.txt`))
+
+	// Output:
+	// my-photo-1.png
+	// notes.tar.gz
+	// file.bashrc
+	// file.txt
+}
+
+func ExampleFileLinks() {
+	text := "# Title\n\nSee [a file](somefile), ![fig](img/a.png \"A\"),\n" +
+		"[node](../3), [web](https://example.com), [again](somefile?T),\n" +
+		"and `[code](code.md)` or [spaced](my%20file.txt).\n\n" +
+		"```md\n[example](example.md)\n```\n"
+	for _, f := range keg.FileLinks(text) {
+		fmt.Println(f)
+	}
+
+	// Output:
+	// somefile
+	// img/a.png
+	// my file.txt
+}
+
+func ExampleAttachText() {
+	text := "# Title\n\nBody.\n\n[^1]: Note\n"
+	fmt.Print(keg.AttachText(text, `photo.png`, `notes.pdf`))
+
+	// Output:
+	// # Title
+	//
+	// Body.
+	//
+	// ![photo](photo.png)
+	//
+	// [notes.pdf](notes.pdf)
+	//
+	// [^1]: Note
+}
+
+func ExampleAssets() {
+	dir, _ := os.MkdirTemp("", "kegassets")
+	defer os.RemoveAll(dir)
+	write := func(name, text string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(text), 0644)
+	}
+	write(`1/README.md`, "# One\n\n![fig](fig.png) and [the data](../2/data.csv)\n")
+	write(`1/fig.png`, `png`)
+	write(`1/old.png`, `png`)
+	write(`2/README.md`, "# Two\n\nSee [the figure](../1/gone.png).\n")
+	write(`2/data.csv`, `a,b`)
+
+	unused, missing, err := keg.Assets(dir)
+	fmt.Println(unused, missing, err)
+
+	// Output:
+	// [1/old.png] [2/../1/gone.png] <nil>
+}
+
+func ExampleParseSize() {
+	for _, s := range []string{`500`, `500K`, `1.5M`, `2GiB`, `big`} {
+		n, err := keg.ParseSize(s)
+		fmt.Println(n, err)
+	}
+
+	// Output:
+	// 500 <nil>
+	// 512000 <nil>
+	// 1572864 <nil>
+	// 2147483648 <nil>
+	// 0 invalid size (want bytes or with K, M, or G): "big"
+}
+
+func ExampleCheckAssets() {
+	dir, _ := os.MkdirTemp("", "kegassets")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, `1`, `.git`), 0700)
+	os.WriteFile(filepath.Join(dir, `1`, `README.md`), []byte("# One\n"), 0644)
+	os.WriteFile(filepath.Join(dir, `1`, `small.PNG`), []byte(`x`), 0644)
+	os.WriteFile(filepath.Join(dir, `1`, `big.png`), make([]byte, 3000), 0644)
+	os.WriteFile(filepath.Join(dir, `1`, `Makefile`), []byte(`x`), 0644)
+	os.WriteFile(filepath.Join(dir, `1`, `.git`, `config`), []byte(`x`), 0644)
+
+	conf := &keg.AssetsConf{MaxSize: `1K`, Allow: []string{`png`}}
+	problems, err := keg.CheckAssets(dir, conf)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	fmt.Println(err)
+	conf.Allow = append(conf.Allow, "")
+	problems, _ = keg.CheckAssets(dir, conf)
+	fmt.Println(len(problems))
+
+	// Output:
+	// node 1 file Makefile extension not allowed (none)
+	// node 1 file big.png is too large (2.9K, max 1K)
+	// <nil>
+	// 1
+}
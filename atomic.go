@@ -0,0 +1,104 @@
+package keg
+
+import (
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rwxrob/fs/file"
+)
+
+// writeAll writes the content of every file (keyed by path) as a single
+// unit. Every file is first staged to a temporary file in the same
+// directory and only when all have been written successfully are they
+// renamed into place. If any cannot be staged nothing is changed. If
+// a rename fails the files already renamed are restored to their
+// original content. Existing file permissions are preserved.
+func writeAll(files map[string]string) error {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	temps := map[string]string{}
+	cleanup := func() {
+		for _, tmp := range temps {
+			os.Remove(tmp)
+		}
+	}
+
+	for _, path := range paths {
+		tmp, err := stage(path, files[path])
+		if err != nil {
+			cleanup()
+			return err
+		}
+		temps[path] = tmp
+	}
+
+	orig := map[string][]byte{}
+	for _, path := range paths {
+		if buf, err := os.ReadFile(path); err == nil {
+			orig[path] = buf
+		}
+	}
+
+	for i, path := range paths {
+		if err := os.Rename(temps[path], path); err != nil {
+			cleanup()
+			for _, done := range paths[:i] {
+				restore(done, orig[done])
+			}
+			return err
+		}
+		delete(temps, path)
+	}
+	return nil
+}
+
+// stage writes buf to a new temporary file next to path (creating the
+// directory if needed) with the same permissions as path (or
+// file.DefaultPerms if it does not yet exist) and returns its name.
+func stage(path, buf string) (string, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, iofs.FileMode(file.DefaultDirPerms)); err != nil {
+		return "", err
+	}
+	mode := iofs.FileMode(file.DefaultPerms)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(dir, `.`+filepath.Base(path)+`.*`)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(buf)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), mode)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// restore puts back the original content of a file replaced by
+// writeAll or removes it if it did not exist before.
+func restore(path string, orig []byte) {
+	if orig == nil {
+		os.Remove(path)
+		return
+	}
+	if tmp, err := stage(path, string(orig)); err == nil {
+		os.Rename(tmp, path)
+	}
+}
@@ -0,0 +1,186 @@
+package keg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rwxrob/keg/kegml"
+	"github.com/rwxrob/pegn"
+	"github.com/rwxrob/pegn/ast"
+	"github.com/rwxrob/to"
+)
+
+// ChangesScanner reads the entries of a dex/changes.md file one at a
+// time (in the manner of bufio.Scanner) so that only as many as are
+// wanted need be parsed. Entries are parsed with kegml.ParseDexEntry.
+// Any other KEGML content before the entries is kept as the Head and
+// anything after them as the Foot (see ParseChanges).
+type ChangesScanner struct {
+	s     pegn.Scanner
+	line  int
+	state int // 0 before, 1 within, 2 after entries
+	entry *DexEntry
+	head  strings.Builder
+	foot  string
+	err   error
+}
+
+// NewChangesScanner returns a ChangesScanner for any input valid for
+// to.String.
+func NewChangesScanner(in any) *ChangesScanner {
+	s := kegml.NewScanner()
+	s.Buffer(to.String(in))
+	return &ChangesScanner{s: s}
+}
+
+// OpenChanges returns a ChangesScanner for the dex/changes.md file of
+// the keg at kegdir.
+func OpenChanges(kegdir string) (*ChangesScanner, error) {
+	s := kegml.NewScanner()
+	if err := s.Open(filepath.Join(kegdir, `dex`, `changes.md`)); err != nil {
+		return nil, err
+	}
+	return &ChangesScanner{s: s}, nil
+}
+
+// Scan advances to the next entry returning false when there are no
+// more or there is an error (see Err). A line that begins like an entry
+// but cannot be parsed is an error (with the line and column at which
+// parsing failed) rather than content since it is almost certainly a
+// damaged entry. So is a git conflict marker line anywhere in the file
+// since neither the entries nor the content around them can be trusted
+// until the conflict is resolved (usually by MakeDex, see Sync).
+func (c *ChangesScanner) Scan() bool {
+	c.entry = nil
+	if c.err != nil || c.state == 2 {
+		return false
+	}
+	for !c.s.Finished() {
+		c.line++
+		m := c.s.Mark()
+		if node := kegml.ParseDexEntry(c.s); node != nil {
+			c.endLine()
+			c.state = 1
+			c.entry, c.err = c.dexEntry(node)
+			return c.err == nil
+		}
+		e, _ := c.s.ErrPop().(pegn.Error)
+		*c.s.Errors() = nil
+		text := c.endLine()
+		if conflictLine(text) {
+			c.err = fmt.Errorf(_ChangesConflict, c.line)
+			return false
+		}
+		dated := e.T != kegml.IsoDate && e.C.E-m.E >= len(`* `+IsoDateFmt)
+		if strings.HasPrefix(text, `* `) && (c.state == 1 || dated) {
+			col := utf8.RuneCount((*c.s.Bytes())[m.E:e.C.E]) + 1
+			c.err = fmt.Errorf(_BadChangesEntry, c.line, col, kegml.RuleNames[e.T])
+			return false
+		}
+		if c.state == 0 {
+			c.head.WriteString(text)
+			continue
+		}
+		c.state = 2
+		c.foot = text + string((*c.s.Bytes())[c.s.Mark().E:])
+		for i, line := range strings.Split(c.foot, "\n") {
+			if conflictLine(line) {
+				c.err = fmt.Errorf(_ChangesConflict, c.line+i)
+				c.foot = ""
+				return false
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// conflictLine returns true if the line is one of the markers git
+// leaves around the conflicting versions of part of a file.
+func conflictLine(line string) bool {
+	line = strings.TrimRight(line, "\r\n")
+	for _, m := range []string{`<<<<<<<`, `|||||||`, `=======`, `>>>>>>>`} {
+		if line == m || strings.HasPrefix(line, m+` `) {
+			return true
+		}
+	}
+	return false
+}
+
+// endLine scans through the end of the current line (if any) returning
+// everything scanned since the beginning of the line.
+func (c *ChangesScanner) endLine() string {
+	m := c.s.Mark()
+	for c.s.Scan() {
+		if c.s.Rune() == '\n' {
+			break
+		}
+	}
+	return string((*c.s.Bytes())[m.E:c.s.Mark().E])
+}
+
+// dexEntry returns a DexEntry for the parsed node.
+func (c *ChangesScanner) dexEntry(node *ast.Node) (*DexEntry, error) {
+	entry := new(DexEntry)
+	for _, n := range node.Nodes() {
+		var err error
+		switch n.T {
+		case kegml.IsoDate:
+			entry.U, err = time.Parse(IsoDateFmt, n.V)
+		case kegml.NodeTitle:
+			entry.T = n.V
+		case kegml.NodeID:
+			entry.N, err = strconv.Atoi(n.V)
+		}
+		if err != nil {
+			return nil, fmt.Errorf(_BadChangesLine, c.line)
+		}
+	}
+	return entry, nil
+}
+
+// Entry returns the most recent entry from Scan.
+func (c *ChangesScanner) Entry() *DexEntry { return c.entry }
+
+// Err returns the first error encountered by Scan.
+func (c *ChangesScanner) Err() error { return c.err }
+
+// Head returns the KEGML content before the entries.
+func (c *ChangesScanner) Head() string { return c.head.String() }
+
+// Foot returns the KEGML content after the entries once Scan has
+// returned false.
+func (c *ChangesScanner) Foot() string { return c.foot }
+
+// ReadChanges reads at most n entries (all if n is less than one) from
+// the dex/changes.md file of the keg at kegdir without parsing any
+// more of it than needed. Like ReadDex, falls back to dex/nodes.tsv
+// (sorted by changes) when there is no dex/changes.md file.
+func ReadChanges(kegdir string, n int) (*Dex, error) {
+	if !HaveDex(kegdir) {
+		dex, err := ReadDex(kegdir)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 && len(*dex) > n {
+			*dex = (*dex)[:n]
+		}
+		return dex, nil
+	}
+	c, err := OpenChanges(kegdir)
+	if err != nil {
+		return nil, err
+	}
+	dex := Dex{}
+	for (n < 1 || len(dex) < n) && c.Scan() {
+		dex = append(dex, c.Entry())
+	}
+	if c.Err() != nil {
+		return nil, c.Err()
+	}
+	return &dex, nil
+}
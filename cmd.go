@@ -4,17 +4,24 @@
 package keg
 
 import (
+	"bufio"
 	_ "embed"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 	"text/template"
+	"time"
 
 	"github.com/charmbracelet/glamour"
+	"github.com/rwxrob/bonzai"
 	Z "github.com/rwxrob/bonzai/z"
 	"github.com/rwxrob/choose"
 	"github.com/rwxrob/conf"
@@ -23,6 +30,7 @@ import (
 	"github.com/rwxrob/fs/file"
 	"github.com/rwxrob/grep"
 	"github.com/rwxrob/help"
+	"github.com/rwxrob/json"
 	"github.com/rwxrob/term"
 	"github.com/rwxrob/to"
 	"github.com/rwxrob/vars"
@@ -39,9 +47,17 @@ var DefColumns = 100
 
 func get(x *Z.Cmd, it string) (keg *Local, id string, entry *DexEntry, err error) {
 
-	keg, err = current(x.Caller)
-	if err != nil {
-		return
+	if l, ok := ParseLink(it); ok && l.Keg != "" {
+		keg, err = mapped(x.Caller, l.Keg)
+		if err != nil {
+			return
+		}
+		it = strconv.Itoa(l.N)
+	} else {
+		keg, err = current(x.Caller)
+		if err != nil {
+			return
+		}
 	}
 
 	switch it {
@@ -165,17 +181,23 @@ func current(x *Z.Cmd) (*Local, error) {
 			return current(x)
 		}
 
-		dir, _ = x.C(`map.` + name)
-		if !(dir == "" || dir == "null") {
-			dir = fs.Tilde2Home(dir)
-			return &Local{Path: dir, Name: name}, nil
+		if local, err := mapped(x, name); err == nil {
+			return local, nil
 		}
 	}
 
+	return here()
+}
+
+// here returns the keg in the current working directory (or its docs
+// directory), which is the current keg (see current) unless changed
+// with KEG_CURRENT or the current var.
+func here() (*Local, error) {
+
 	// check if current working directory has a keg
-	dir, _ = os.Getwd()
-	if fs.Exists(filepath.Join(dir, `keg`)) {
-		name = filepath.Base(dir)
+	dir, _ := os.Getwd()
+	if file.Exists(filepath.Join(dir, `keg`)) {
+		name := filepath.Base(dir)
 		if name == `docs` {
 			name = filepath.Base(filepath.Dir(dir))
 		}
@@ -183,9 +205,8 @@ func current(x *Z.Cmd) (*Local, error) {
 	}
 
 	// check if current working directory has a docs/keg
-	dir, _ = os.Getwd()
-	if fs.Exists(filepath.Join(dir, `docs`, `keg`)) {
-		name = filepath.Base(dir)
+	if file.Exists(filepath.Join(dir, `docs`, `keg`)) {
+		name := filepath.Base(dir)
 		dir = filepath.Join(dir, `docs`)
 		return &Local{Path: dir, Name: name}, nil
 	}
@@ -193,6 +214,117 @@ func current(x *Z.Cmd) (*Local, error) {
 	return nil, fmt.Errorf(_NoKegsFound)
 }
 
+// mapped returns the keg with the given name from the map in conf.
+func mapped(x *Z.Cmd, name string) (*Local, error) {
+	for _, k := range kegMap(x) {
+		if k.Name == name {
+			return &Local{Name: name, Path: k.Dir}, nil
+		}
+	}
+	return nil, fmt.Errorf(_KegNotMapped, name)
+}
+
+// kegMap returns the map of kegs from conf (see ReadMap) or an empty
+// list if there is none.
+func kegMap(x *Z.Cmd) []MapEntry {
+	data, _ := x.C(`map`)
+	list, _ := ReadMap(data)
+	return list
+}
+
+// includes returns the dex as include links (see Dex.AsIncludes) for
+// the keg. If the current working directory is a different keg (see
+// here) because the current keg was changed for the command while
+// editing another, the links are to the keg by name instead (see
+// Dex.AsKegIncludes).
+func includes(keg *Local, dex Dex) string {
+	if cwd, err := here(); err == nil && !sameDir(cwd.Path, keg.Path) {
+		return dex.AsKegIncludes(keg.Name)
+	}
+	return dex.AsIncludes()
+}
+
+// sameDir returns true if both paths refer to the same directory.
+func sameDir(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
+
+// ------------------------------ publish -----------------------------
+
+// publishConf returns the ReadPublishConf for the keg at kegpath but
+// with the mode overridden by the publish var if set.
+func publishConf(x *Z.Cmd, kegpath string) PublishConf {
+	conf := ReadPublishConf(kegpath)
+	if mode, _ := x.Get(`publish`); !(mode == "" || mode == "null") {
+		conf.Mode = mode
+	}
+	return conf
+}
+
+// publish calls Publish for the keg at kegpath with the change honoring
+// the publish var (see publishConf).
+func publish(x *Z.Cmd, kegpath string, change *Change) error {
+	conf := publishConf(x, kegpath)
+	warnAssets(conf, kegpath)
+	return conf.Publish(kegpath, change, false)
+}
+
+// dexUpdate calls DexUpdate and then warnDupes for the entry.
+func dexUpdate(x *Z.Cmd, kegpath string, entry *DexEntry) error {
+	if err := DexUpdate(kegpath, entry); err != nil {
+		return err
+	}
+	warnDupes(x, kegpath, Dex{entry})
+	return nil
+}
+
+// warnDupes logs, if the dupes var is set to warn, any other nodes with
+// the same or nearly the same title as each of the (just updated)
+// entries (see Dex.TitleDupes) since they make choosing by REGEXP
+// harder.
+func warnDupes(x *Z.Cmd, kegpath string, entries Dex) {
+	if warn, _ := x.Get(`dupes`); warn != `warn` {
+		return
+	}
+	dex, err := ReadDex(kegpath)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		for _, e := range dex.TitleDupes(entry) {
+			log.Printf(_DupeTitle, entry.N, e.N, e.T)
+		}
+	}
+}
+
+// warnAssets logs any problems with the files of nodes (see
+// CheckAssets) before publishing unless the keg file blocks publishing
+// for them (which is reported by Publish instead).
+func warnAssets(conf PublishConf, kegpath string) {
+	if conf.Mode == PublishNone {
+		return
+	}
+	info, err := ReadKegInfo(kegpath)
+	if err != nil || info.Assets == nil || info.Assets.Block {
+		return
+	}
+	problems, err := CheckAssets(kegpath, info.Assets)
+	if err != nil {
+		log.Println(err)
+	}
+	for _, p := range problems {
+		log.Println(p)
+	}
+}
+
 // ------------------------------- Cmds -------------------------------
 
 var Cmd = &Z.Cmd{
@@ -212,8 +344,11 @@ var Cmd = &Z.Cmd{
 	Commands: []*Z.Cmd{
 		editCmd, help.Cmd, conf.Cmd, vars.Cmd,
 		indexCmd, createCmd, currentCmd, directoryCmd, deleteCmd,
-		lastCmd, changesCmd, titlesCmd, initCmd, randomCmd,
-		importCmd, grepCmd, viewCmd, columnsCmd, linkCmd, tagCmd,
+		lastCmd, changesCmd, titlesCmd, initCmd, randomCmd, todayCmd,
+		importCmd, grepCmd, viewCmd, columnsCmd, linkCmd, backlinksCmd, tagCmd,
+		publishCmd, syncCmd, watchCmd, mapCmd, useCmd, allCmd,
+		sendCmd, appendCmd, splitCmd, mergeCmd, attachCmd, assetsCmd,
+		checkCmd, dupesCmd,
 	},
 
 	Shortcuts: Z.ArgMap{
@@ -282,13 +417,9 @@ var titlesCmd = &Z.Cmd{
 			return err
 		}
 
-		if term.IsInteractive() {
-			Z.Page(dex.WithTitleTextExp(re).Pretty())
-			return nil
-		}
-
-		fmt.Print(dex.WithTitleTextExp(re).AsIncludes())
-		return nil
+		found := dex.WithTitleTextExp(re)
+		return printDex(x.Caller, found, includes(keg, found),
+			func() { Z.Page(found.Pretty()) })
 	},
 }
 
@@ -352,7 +483,7 @@ var deleteCmd = &Z.Cmd{
 		if err := DexRemove(keg.Path, entry); err != nil {
 			return err
 		}
-		return Publish(keg.Path)
+		return publish(x.Caller, keg.Path, &Change{Op: OpDeleted, Nodes: Dex{entry}})
 
 	},
 }
@@ -360,13 +491,16 @@ var deleteCmd = &Z.Cmd{
 var indexCmd = &Z.Cmd{
 	Name:        `index`,
 	Aliases:     []string{`dex`},
-	Commands:    []*Z.Cmd{help.Cmd, dexUpdateCmd},
+	Commands:    []*Z.Cmd{help.Cmd, dexUpdateCmd, dexVerifyCmd},
 	Summary:     help.S(_index),
 	Description: help.D(_index),
 }
 
 var dexUpdateCmd = &Z.Cmd{
 	Name:        `update`,
+	Usage:       `[help|quick|full]`,
+	Params:      []string{`quick`, `full`},
+	MaxArgs:     1,
 	Commands:    []*Z.Cmd{help.Cmd},
 	Summary:     help.S(_index_update),
 	Description: help.D(_index_update),
@@ -375,10 +509,38 @@ var dexUpdateCmd = &Z.Cmd{
 		if err != nil {
 			return err
 		}
+		if len(args) > 0 && args[0] == `quick` {
+			return RefreshDex(keg.Path)
+		}
 		return MakeDex(keg.Path)
 	},
 }
 
+var dexVerifyCmd = &Z.Cmd{
+	Name:        `verify`,
+	Aliases:     []string{`check`},
+	Commands:    []*Z.Cmd{help.Cmd},
+	Summary:     help.S(_index_verify),
+	Description: help.D(_index_verify),
+	Call: func(x *Z.Cmd, args ...string) error {
+		keg, err := current(x.Caller.Caller) // keg dex verify
+		if err != nil {
+			return err
+		}
+		drift, err := VerifyDex(keg.Path)
+		if err != nil {
+			return err
+		}
+		for _, d := range drift {
+			term.Print(d)
+		}
+		if len(drift) > 0 {
+			return fmt.Errorf(_DexDrift, len(drift))
+		}
+		return nil
+	},
+}
+
 var lastCmd = &Z.Cmd{
 	Name:        `last`,
 	Usage:       `[help|dir|id|title|time]`,
@@ -396,14 +558,13 @@ var lastCmd = &Z.Cmd{
 		}
 
 		last := Last(keg.Path)
+		if last == nil {
+			return fmt.Errorf(_NoNodesFound, keg.Path)
+		}
 
 		if len(args) == 0 {
-			if term.IsInteractive() {
-				fmt.Print(last.Pretty())
-			} else {
-				fmt.Print(last.MD())
-			}
-			return nil
+			return printDex(x.Caller, Dex{last}, last.MD()+"\n",
+				func() { fmt.Print(last.Pretty()) })
 		}
 
 		switch args[0] {
@@ -468,28 +629,13 @@ var changesCmd = &Z.Cmd{
 			return err
 		}
 
-		path := filepath.Join(keg.Path, `dex/changes.md`)
-		if !fs.Exists(path) {
-			return fmt.Errorf(_FileNotFound, `dex/changes.md`)
-		}
-
-		lines, err := file.Head(path, n)
+		dex, err := ReadChanges(keg.Path, n)
 		if err != nil {
 			return err
 		}
 
-		dex, err := ParseDex(strings.Join(lines, "\n"))
-		if err != nil {
-			return nil
-		}
-
-		if term.IsInteractive() {
-			fmt.Print(dex.Pretty())
-			return nil
-		}
-
-		fmt.Print(dex.AsIncludes())
-		return nil
+		return printDex(x.Caller, *dex, includes(keg, *dex),
+			func() { fmt.Print(dex.Pretty()) })
 	},
 }
 
@@ -500,7 +646,7 @@ var initCmd = &Z.Cmd{
 	Summary:     help.S(_init),
 	Description: help.D(_init),
 
-	Call: func(_ *Z.Cmd, _ ...string) error {
+	Call: func(x *Z.Cmd, _ ...string) error {
 
 		if fs.NotExists(`keg`) {
 			if err := file.Overwrite(`keg`, _kegyaml); err != nil {
@@ -526,7 +672,7 @@ var initCmd = &Z.Cmd{
 			return err
 		}
 
-		return Publish(dir)
+		return publish(x.Caller, dir, &Change{Op: OpInitialized})
 	},
 }
 var editCmd = &Z.Cmd{
@@ -571,30 +717,123 @@ var editCmd = &Z.Cmd{
 			if err := DexRemove(keg.Path, entry); err != nil {
 				return err
 			}
-			return Publish(keg.Path)
+			return publish(x.Caller, keg.Path, &Change{Op: OpDeleted, Nodes: Dex{entry}})
 		} else {
-			if err := DexUpdate(keg.Path, entry); err != nil {
+			if err := dexUpdate(x.Caller, keg.Path, entry); err != nil {
 				return err
 			}
 		}
 
 		atime := fs.ModTime(path)
 		if atime.After(btime) {
-			return Publish(keg.Path)
+			return publish(x.Caller, keg.Path, &Change{Op: OpEdited, Nodes: Dex{entry}})
 		}
 		return nil
 
 	},
 }
 
+// templateDirs returns the directories in which to look for node
+// templates for the keg, its own first and then the configuration
+// directory.
+func templateDirs(keg *Local) []string {
+	dirs := []string{filepath.Join(keg.Path, TemplatesDir)}
+	if c, ok := Z.Conf.(conf.C); ok {
+		dirs = append(dirs, filepath.Join(c.DirPath(), TemplatesDir))
+	}
+	return dirs
+}
+
+// promptStdin returns a prompt function for ExecTemplate that reads
+// each answer as a line from standard input (printing the question
+// first if interactive).
+func promptStdin() func(q string) (string, error) {
+	r := bufio.NewReader(os.Stdin)
+	return func(q string) (string, error) {
+		if term.IsInteractive() {
+			fmt.Print(q + `: `)
+		}
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+}
+
+// templateComp completes the names of node templates (see Templates)
+// as well as help and sample.
+type templateComp struct{}
+
+func (templateComp) Complete(x bonzai.Command, args ...string) []string {
+	if len(args) == 0 {
+		return []string{x.GetName()}
+	}
+	names := []string{`help`, SampleTemplate}
+	if cmd, ok := x.(*Z.Cmd); ok {
+		if keg, err := current(cmd.Caller); err == nil {
+			for _, name := range Templates(templateDirs(keg)...) {
+				if name != SampleTemplate {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	var list []string
+	for _, name := range names {
+		if strings.HasPrefix(name, args[0]) {
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+// createArgs are the arguments to create (see parseCreateArgs).
+type createArgs struct {
+	title string // from --title
+	stdin bool   // body from standard input (-)
+	tmpl  string // template name (or sample)
+}
+
+// parseCreateArgs parses the arguments to create which may include
+// a template name, --title TITLE (or --title=TITLE), and - (to read
+// the body from standard input) in any order.
+func parseCreateArgs(args []string) (createArgs, error) {
+	var a createArgs
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == `-`:
+			a.stdin = true
+		case arg == `--title`:
+			if i+1 >= len(args) {
+				return a, fmt.Errorf(_MissingArg, arg)
+			}
+			i++
+			a.title = args[i]
+		case strings.HasPrefix(arg, `--title=`):
+			a.title = arg[len(`--title=`):]
+		case a.tmpl == "" && !strings.HasPrefix(arg, `-`):
+			a.tmpl = arg
+		default:
+			return a, fmt.Errorf(_UnknownArg, arg)
+		}
+	}
+	return a, nil
+}
+
 var createCmd = &Z.Cmd{
 	Name:        `create`,
 	Aliases:     []string{`c`},
-	Params:      []string{`sample`},
-	MaxArgs:     1,
+	Usage:       `[help|sample|TEMPLATE] [--title TITLE] [-]`,
+	MaxArgs:     4,
 	Summary:     help.S(_create),
 	Description: help.D(_create),
 	Commands:    []*Z.Cmd{help.Cmd},
+	Comp:        templateComp{},
+
+	Dynamic: template.FuncMap{
+		`templates`: func() string { return TemplatesDir },
+	},
 
 	Call: func(x *Z.Cmd, args ...string) error {
 
@@ -603,22 +842,82 @@ var createCmd = &Z.Cmd{
 			return err
 		}
 
-		entry, err := MakeNode(keg.Path)
+		opts, err := parseCreateArgs(args)
 		if err != nil {
 			return err
 		}
+		batch := opts.stdin || opts.title != ""
+
+		var tmpl string
+		if opts.tmpl != "" {
+			path, err := FindTemplate(opts.tmpl, templateDirs(keg)...)
+			if err != nil && opts.tmpl != SampleTemplate {
+				return err
+			}
+			if path != "" {
+				buf, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				tmpl = string(buf)
+			}
+		}
 
-		if len(args) > 0 && args[0] == `sample` {
-			if err := WriteSample(keg.Path, entry); err != nil {
+		var body string
+		if opts.stdin {
+			buf, err := io.ReadAll(os.Stdin)
+			if err != nil {
 				return err
 			}
+			body = string(buf)
 		}
 
-		if err := Edit(keg.Path, entry.N); err != nil {
+		entry, err := MakeNode(keg.Path)
+		if err != nil {
 			return err
 		}
-
 		path := filepath.Join(keg.Path, entry.ID(), `README.md`)
+		fail := func(err error) error {
+			os.RemoveAll(filepath.Dir(path))
+			return err
+		}
+
+		var text string
+		switch {
+		case tmpl != "":
+			prompt := promptStdin()
+			if opts.stdin {
+				prompt = nil
+			}
+			data := TemplateData{
+				ID: entry.N, Keg: keg.Name, Title: opts.title,
+				Time: time.Now().UTC(),
+			}
+			text, err = ExecTemplate(tmpl, data, prompt)
+		case opts.tmpl != "":
+			text = SampleNodeReadme
+		}
+		if err == nil && batch {
+			text, err = NodeText(opts.title, text+body)
+		}
+		if err == nil && text != "" {
+			err = file.Overwrite(path, text)
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		if batch {
+			if err := dexUpdate(x.Caller, keg.Path, entry); err != nil {
+				return fail(err)
+			}
+			fmt.Println(entry.N)
+			return publish(x.Caller, keg.Path, &Change{Op: OpCreated, Nodes: Dex{entry}})
+		}
+
+		if err := Edit(keg.Path, entry.N); err != nil {
+			return fail(err)
+		}
 
 		if file.IsEmpty(path) {
 			if err = os.RemoveAll(filepath.Dir(path)); err != nil {
@@ -627,123 +926,509 @@ var createCmd = &Z.Cmd{
 			return nil
 		}
 
-		if err := DexUpdate(keg.Path, entry); err != nil {
-			return err
+		if err := dexUpdate(x.Caller, keg.Path, entry); err != nil {
+			return fail(err)
 		}
 
-		return Publish(keg.Path)
+		return publish(x.Caller, keg.Path, &Change{Op: OpCreated, Nodes: Dex{entry}})
 	},
 }
 
-var randomCmd = &Z.Cmd{
-	Name:        `random`,
-	Aliases:     []string{`rand`},
-	Usage:       `[help|title|id|dir|edit]`,
-	Params:      []string{`title`, `id`, `dir`, `edit`},
-	MaxArgs:     1,
-	Summary:     help.S(_random),
-	Description: help.D(_random),
+var appendCmd = &Z.Cmd{
+	Name:        `append`,
+	Aliases:     []string{`add`},
+	Usage:       `(help|ID|same|last|REGEXP) [--bullet] (-|TEXT...)`,
+	MinArgs:     2,
+	Summary:     help.S(_append),
+	Description: help.D(_append),
 	Commands:    []*Z.Cmd{help.Cmd},
 
 	Call: func(x *Z.Cmd, args ...string) error {
-		if len(args) == 0 {
-			args = append(args, `edit`)
+
+		var bullet bool
+		words := []string{}
+		for _, arg := range args[1:] {
+			if arg == `--bullet` && !bullet && len(words) == 0 {
+				bullet = true
+				continue
+			}
+			words = append(words, arg)
 		}
-		keg, err := current(x.Caller)
+		if len(words) == 0 {
+			return fmt.Errorf(_MissingArg, `TEXT`)
+		}
+
+		text := strings.Join(words, " ")
+		if len(words) == 1 && words[0] == `-` {
+			buf, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			text = string(buf)
+		}
+
+		keg, _, entry, err := get(x, args[0])
 		if err != nil {
 			return err
 		}
-		dex, err := ReadDex(keg.Path)
-		r := dex.Random()
-		switch args[0] {
-		case `id`:
-			term.Print(r.N)
-		case `title`:
-			term.Print(r.T)
-		case `edit`:
-			return editCmd.Call(x, strconv.Itoa(r.N))
-		case `dir`:
-			term.Print(filepath.Join(strconv.Itoa(r.N)))
+		if entry == nil {
+			return fmt.Errorf(_NodeNotFound, args[0])
 		}
-		return nil
+
+		if err := AppendNode(keg.Path, entry.N, text, bullet); err != nil {
+			return err
+		}
+		if err := dexUpdate(x.Caller, keg.Path, entry); err != nil {
+			return err
+		}
+		return publish(x.Caller, keg.Path, &Change{Op: OpEdited, Nodes: Dex{entry}})
 	},
 }
 
-var importCmd = &Z.Cmd{
-	Name:        `import`,
-	Usage:       `[help|(DIR|NODEDIR)...]`,
+var splitCmd = &Z.Cmd{
+	Name:        `split`,
+	Usage:       `(help|ID|same|last|REGEXP)`,
+	NumArgs:     1,
+	Summary:     help.S(_split),
+	Description: help.D(_split),
 	Commands:    []*Z.Cmd{help.Cmd},
-	Summary:     help.S(_import),
-	Description: help.D(_import),
 
 	Call: func(x *Z.Cmd, args ...string) error {
-
-		keg, err := current(x.Caller)
+		keg, _, entry, err := get(x, args[0])
 		if err != nil {
 			return err
 		}
-
-		if len(args) == 0 {
-			d := dir.Abs()
-			if d == "" {
-				return fmt.Errorf(_AbsPathFail)
-			}
-			args = append(args, d)
+		if entry == nil {
+			return fmt.Errorf(_NodeNotFound, args[0])
 		}
-
-		if err := Import(keg.Path, args...); err != nil {
+		changed, err := Split(keg.Path, entry.N)
+		if err != nil {
 			return err
 		}
-
-		if err := MakeDex(keg.Path); err != nil {
-			return err
+		warnDupes(x.Caller, keg.Path, changed)
+		for _, e := range changed[1:] {
+			fmt.Println(e.N)
 		}
-
-		return Publish(keg.Path)
-
+		return publish(x.Caller, keg.Path, &Change{Op: OpSplit, Nodes: changed})
 	},
 }
 
-// columns first looks for term.WinSize.Col to have been set. If not
-// found, the columns variable (from vars) is checked and used if found.
-// Finally, the package global DefColumns will be used.
-func columns(x *Z.Cmd) int {
-
-	col := int(term.WinSize.Col) // only > 0 for interactive terminals
-	if col > 0 {
-		return col
-	}
+var mergeCmd = &Z.Cmd{
+	Name:        `merge`,
+	Usage:       `(help|(ID|same|last|REGEXP) (ID|same|last|REGEXP))`,
+	NumArgs:     2,
+	Summary:     help.S(_merge),
+	Description: help.D(_merge),
+	Commands:    []*Z.Cmd{help.Cmd},
 
-	colstr, err := x.Caller.Get(`columns`)
-	if err == nil && colstr != "" {
-		col, err = strconv.Atoi(colstr)
-		if err == nil {
-			return col
+	Call: func(x *Z.Cmd, args ...string) error {
+		var entries [2]*DexEntry
+		var keg *Local
+		for i, arg := range args {
+			k, _, entry, err := get(x, arg)
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				return fmt.Errorf(_NodeNotFound, arg)
+			}
+			if keg != nil && !sameDir(keg.Path, k.Path) {
+				return fmt.Errorf(_MergeKegs, args[0], args[1])
+			}
+			keg, entries[i] = k, entry
 		}
-	}
-
-	return DefColumns
-
+		changed, err := Merge(keg.Path, entries[0].N, entries[1].N)
+		if err != nil {
+			return err
+		}
+		warnDupes(x.Caller, keg.Path, changed)
+		return publish(x.Caller, keg.Path, &Change{
+			Op: OpMerged, Nodes: changed,
+			Note: `from ` + entries[1].ID(),
+		})
+	},
 }
 
-var columnsCmd = &Z.Cmd{
-	Name:        `columns`,
-	Usage:       `(help|col|cols)`,
-	Summary:     help.S(_columns),
-	Description: help.D(_columns),
-	MaxArgs:     1,
+var attachCmd = &Z.Cmd{
+	Name:        `attach`,
+	Usage:       `(help|(ID|same|last|REGEXP) FILE...)`,
+	MinArgs:     2,
+	Summary:     help.S(_attach),
+	Description: help.D(_attach),
 	Commands:    []*Z.Cmd{help.Cmd},
-	Dynamic:     template.FuncMap{`columns`: func() int { return DefColumns }},
 
 	Call: func(x *Z.Cmd, args ...string) error {
-		term.Print(columns(x))
-		return nil
-	},
-}
-
-type grepChoice struct {
-	hit grep.Result
-	str string
+		keg, _, entry, err := get(x, args[0])
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return fmt.Errorf(_NodeNotFound, args[0])
+		}
+		names, err := Attach(keg.Path, entry.N, args[1:]...)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		if err := dexUpdate(x.Caller, keg.Path, entry); err != nil {
+			return err
+		}
+		return publish(x.Caller, keg.Path, &Change{Op: OpEdited, Nodes: Dex{entry}})
+	},
+}
+
+var assetsCmd = &Z.Cmd{
+	Name:        `assets`,
+	Usage:       `[help]`,
+	MaxArgs:     0,
+	Summary:     help.S(_assets),
+	Description: help.D(_assets),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, _ ...string) error {
+		keg, err := current(x.Caller)
+		if err != nil {
+			return err
+		}
+		unused, missing, err := Assets(keg.Path)
+		if err != nil {
+			return err
+		}
+		for _, a := range unused {
+			fmt.Println(`unused  ` + a.String())
+		}
+		for _, a := range missing {
+			fmt.Println(`missing ` + a.String())
+		}
+		return nil
+	},
+}
+
+var checkCmd = &Z.Cmd{
+	Name:        `check`,
+	Aliases:     []string{`lint`},
+	Commands:    []*Z.Cmd{help.Cmd, checkAssetsCmd},
+	Summary:     help.S(_check),
+	Description: help.D(_check),
+}
+
+var checkAssetsCmd = &Z.Cmd{
+	Name:        `assets`,
+	Aliases:     []string{`files`},
+	Commands:    []*Z.Cmd{help.Cmd},
+	Summary:     help.S(_check_assets),
+	Description: help.D(_check_assets),
+	Call: func(x *Z.Cmd, args ...string) error {
+		keg, err := current(x.Caller.Caller) // keg check assets
+		if err != nil {
+			return err
+		}
+		info, err := ReadKegInfo(keg.Path)
+		if err != nil {
+			return err
+		}
+		problems, err := CheckAssets(keg.Path, info.Assets)
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf(_AssetProblems, len(problems))
+		}
+		return nil
+	},
+}
+
+var dupesCmd = &Z.Cmd{
+	Name:        `dupes`,
+	Aliases:     []string{`dups`},
+	Usage:       `[help]`,
+	MaxArgs:     0,
+	Summary:     help.S(_dupes),
+	Description: help.D(_dupes),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, _ ...string) error {
+		keg, err := current(x.Caller)
+		if err != nil {
+			return err
+		}
+		dex, err := ReadDex(keg.Path)
+		if err != nil {
+			return err
+		}
+		dupes, err := Dupes(keg.Path)
+		if err != nil {
+			return err
+		}
+		for _, d := range dupes {
+			a, b := dex.Lookup(d.A), dex.Lookup(d.B)
+			if a == nil || b == nil {
+				continue
+			}
+			switch d.Kind {
+			case DupeSame:
+				fmt.Printf("same %v %v: %v\n", d.A, d.B, a.T)
+			case DupeNear:
+				fmt.Printf("near %v %v: %v ~ %v\n", d.A, d.B, a.T, b.T)
+			default:
+				fmt.Printf("body %v %v (%.0f%%): %v ~ %v\n", d.A, d.B, d.Score*100, a.T, b.T)
+			}
+		}
+		return nil
+	},
+}
+
+var todayCmd = &Z.Cmd{
+	Name:        `today`,
+	Usage:       `[help|-|TEXT...]`,
+	Summary:     help.S(_today),
+	Description: help.D(_today),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Dynamic: template.FuncMap{
+		`titlefmt`: func() string { return DefaultJournalTitle },
+		`stampfmt`: func() string { return DefaultJournalStamp },
+	},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+
+		keg, err := current(x.Caller)
+		if err != nil {
+			return err
+		}
+		info, err := ReadKegInfo(keg.Path)
+		if err != nil {
+			return err
+		}
+
+		var text string
+		switch {
+		case len(args) == 1 && args[0] == `-`:
+			buf, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			text = string(buf)
+		default:
+			text = strings.Join(args, " ")
+		}
+
+		now := time.Now()
+		entry, created, err := Today(keg.Path, info.Journal, now)
+		if err != nil {
+			return err
+		}
+		readme := filepath.Join(keg.Path, entry.ID(), `README.md`)
+		before, err := os.ReadFile(readme)
+		if err != nil {
+			return err
+		}
+		if err := AppendJournal(keg.Path, entry.N, info.Journal, now, text); err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			if err := Edit(keg.Path, entry.N); err != nil {
+				return err
+			}
+			if _, err := TrimJournal(keg.Path, entry.N, info.Journal, now); err != nil {
+				return err
+			}
+			after, err := os.ReadFile(readme)
+			if err != nil {
+				return err
+			}
+			if !created && strings.TrimSpace(string(after)) == strings.TrimSpace(string(before)) {
+				return nil
+			}
+		}
+
+		if err := dexUpdate(x.Caller, keg.Path, entry); err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			fmt.Println(entry.N)
+		}
+		op := OpEdited
+		if created {
+			op = OpCreated
+		}
+		return publish(x.Caller, keg.Path, &Change{Op: op, Nodes: Dex{entry}})
+	},
+}
+
+var randomCmd = &Z.Cmd{
+	Name:        `random`,
+	Aliases:     []string{`rand`},
+	Usage:       `[help|title|id|dir|edit|show]`,
+	Params:      []string{`title`, `id`, `dir`, `edit`, `show`},
+	MaxArgs:     1,
+	Summary:     help.S(_random),
+	Description: help.D(_random),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		if len(args) == 0 {
+			args = append(args, `edit`)
+		}
+		keg, err := current(x.Caller)
+		if err != nil {
+			return err
+		}
+		dex, err := ReadDex(keg.Path)
+		r := dex.Random()
+		switch args[0] {
+		case `id`:
+			term.Print(r.N)
+		case `title`:
+			term.Print(r.T)
+		case `edit`:
+			return editCmd.Call(x, strconv.Itoa(r.N))
+		case `dir`:
+			term.Print(filepath.Join(strconv.Itoa(r.N)))
+		case `show`:
+			return printDex(x.Caller, Dex{r}, includes(keg, Dex{r}),
+				func() { fmt.Print(r.Pretty()) })
+		}
+		return nil
+	},
+}
+
+var importCmd = &Z.Cmd{
+	Name:        `import`,
+	Usage:       `[help|(DIR|NODEDIR)...]`,
+	Commands:    []*Z.Cmd{help.Cmd},
+	Summary:     help.S(_import),
+	Description: help.D(_import),
+
+	Call: func(x *Z.Cmd, args ...string) error {
+
+		keg, err := current(x.Caller)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			d := dir.Abs()
+			if d == "" {
+				return fmt.Errorf(_AbsPathFail)
+			}
+			args = append(args, d)
+		}
+
+		dex, err := Import(keg.Path, args...)
+		if err != nil {
+			return err
+		}
+
+		if err := MakeDex(keg.Path); err != nil {
+			return err
+		}
+
+		return publish(x.Caller, keg.Path, &Change{Op: OpImported, Nodes: dex})
+
+	},
+}
+
+// columns first looks for term.WinSize.Col to have been set. If not
+// found, the columns variable (from vars) is checked and used if found.
+// Finally, the package global DefColumns will be used.
+func columns(x *Z.Cmd) int {
+
+	col := int(term.WinSize.Col) // only > 0 for interactive terminals
+	if col > 0 {
+		return col
+	}
+
+	colstr, err := x.Caller.Get(`columns`)
+	if err == nil && colstr != "" {
+		col, err = strconv.Atoi(colstr)
+		if err == nil {
+			return col
+		}
+	}
+
+	return DefColumns
+
+}
+
+// Output formats for listing commands (see outputFormat).
+const (
+	FormatJSON   = `json`
+	FormatTSV    = `tsv`
+	FormatMD     = `md`
+	FormatPretty = `pretty`
+)
+
+// outputFormat returns the output format (one of the Format* constants)
+// to be used by all listing commands. The KEG_FORMAT environment
+// variable beats the format var. If neither is set, FormatPretty is
+// used for interactive terminals and FormatMD otherwise.
+func outputFormat(x *Z.Cmd) (string, error) {
+	f := os.Getenv(`KEG_FORMAT`)
+	if f == "" {
+		f, _ = x.Get(`format`)
+	}
+	switch f {
+	case FormatJSON, FormatTSV, FormatMD, FormatPretty:
+		return f, nil
+	case "", "null":
+		if term.IsInteractive() {
+			return FormatPretty, nil
+		}
+		return FormatMD, nil
+	}
+	return "", fmt.Errorf(_BadFormat, f)
+}
+
+// printDex prints the dex in the output format (see outputFormat). The
+// md string is printed for FormatMD and pretty is called for
+// FormatPretty since each command presents these a little differently.
+func printDex(x *Z.Cmd, dex Dex, md string, pretty func()) error {
+	f, err := outputFormat(x)
+	if err != nil {
+		return err
+	}
+	switch f {
+	case FormatJSON:
+		buf, err := dex.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.TrimSpace(string(buf)))
+	case FormatTSV:
+		fmt.Print(dex.TSV())
+	case FormatMD:
+		fmt.Print(md)
+	default:
+		pretty()
+	}
+	return nil
+}
+
+var columnsCmd = &Z.Cmd{
+	Name:        `columns`,
+	Usage:       `(help|col|cols)`,
+	Summary:     help.S(_columns),
+	Description: help.D(_columns),
+	MaxArgs:     1,
+	Commands:    []*Z.Cmd{help.Cmd},
+	Dynamic:     template.FuncMap{`columns`: func() int { return DefColumns }},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		term.Print(columns(x))
+		return nil
+	},
+}
+
+type grepChoice struct {
+	hit grep.Result
+	str string
 }
 
 func (c grepChoice) String() string { return c.str }
@@ -775,7 +1460,12 @@ var grepCmd = &Z.Cmd{
 			return err
 		}
 
-		if term.IsInteractive() {
+		format, err := outputFormat(x.Caller)
+		if err != nil {
+			return err
+		}
+
+		if format == FormatPretty && term.IsInteractive() {
 
 			var choices []grepChoice
 			for _, hit := range results.Hits {
@@ -820,6 +1510,7 @@ var grepCmd = &Z.Cmd{
 			return err
 		}
 		var lastid int
+		hits := Dex{}
 		for _, hit := range results.Hits {
 			id, err := strconv.Atoi(filepath.Base(filepath.Dir(hit.File)))
 			if err != nil {
@@ -829,9 +1520,12 @@ var grepCmd = &Z.Cmd{
 				continue
 			}
 			lastid = id
-			fmt.Println(dex.Lookup(id).AsInclude())
+			if entry := dex.Lookup(id); entry != nil {
+				hits.Add(entry)
+			}
 		}
-		return nil
+		return printDex(x.Caller, hits, includes(keg, hits),
+			func() { fmt.Print(hits.Pretty()) })
 	},
 }
 
@@ -852,13 +1546,20 @@ var viewCmd = &Z.Cmd{
 
 	Call: func(x *Z.Cmd, args ...string) error {
 
-		keg, err := current(x.Caller)
+		id := args[0]
+
+		var keg *Local
+		var err error
+		if l, ok := ParseLink(id); ok && l.Keg != "" {
+			keg, err = mapped(x.Caller, l.Keg)
+			id = strconv.Itoa(l.N)
+		} else {
+			keg, err = current(x.Caller)
+		}
 		if err != nil {
 			return err
 		}
 
-		id := args[0]
-
 		switch id {
 
 		case "same":
@@ -913,6 +1614,7 @@ var viewCmd = &Z.Cmd{
 		if err != nil {
 			return err
 		}
+		text := ExpandLinks(string(buf), keg.Path, kegMap(x.Caller))
 
 		var r *glamour.TermRenderer
 		if !term.IsInteractive() {
@@ -923,7 +1625,7 @@ var viewCmd = &Z.Cmd{
 			if err != nil {
 				return err
 			}
-			out, err := r.Render(string(buf))
+			out, err := r.Render(text)
 			if err != nil {
 				return err
 			}
@@ -950,7 +1652,7 @@ var viewCmd = &Z.Cmd{
 			}
 		}
 
-		out, err := r.Render(string(buf))
+		out, err := r.Render(text)
 		if err != nil {
 			return err
 		}
@@ -999,6 +1701,39 @@ var linkCmd = &Z.Cmd{
 	},
 }
 
+var backlinksCmd = &Z.Cmd{
+	Name:        `backlinks`,
+	Aliases:     []string{`linked`},
+	Usage:       `(help|ID|same|last|REGEXP)`,
+	NumArgs:     1,
+	Summary:     help.S(_backlinks),
+	Description: help.D(_backlinks),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		keg, _, entry, err := get(x, args[0])
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return fmt.Errorf(_NodeNotFound, args[0])
+		}
+		name := keg.Name
+		kegs := kegMap(x.Caller)
+		var mapped bool
+		for _, k := range kegs {
+			if sameDir(k.Dir, keg.Path) {
+				name, mapped = k.Name, true
+			}
+		}
+		if !mapped {
+			kegs = append(kegs, MapEntry{Name: keg.Name, Dir: keg.Path})
+		}
+		all := AllDex(kegs, LinksFilter(keg.Path, name, entry.N))
+		return printAll(x.Caller, all, false)
+	},
+}
+
 var tagCmd = &Z.Cmd{
 	Name:        `tag`,
 	Aliases:     []string{`tags`},
@@ -1027,31 +1762,487 @@ var tagCmd = &Z.Cmd{
 
 			if args[0] == `list` {
 				tags := Tags(keg.Path)
-				if len(tags) > 0 {
-					term.Print(tags)
+				f, err := outputFormat(x.Caller)
+				if err != nil {
+					return err
+				}
+				switch f {
+				case FormatJSON:
+					names := []string{}
+					for _, t := range strings.Fields(tags) {
+						names = append(names, `"`+json.Escape(t)+`"`)
+					}
+					fmt.Println(`[` + strings.Join(names, `,`) + `]`)
+				case FormatTSV:
+					for _, t := range strings.Fields(tags) {
+						fmt.Println(t)
+					}
+				default:
+					if len(tags) > 0 {
+						term.Print(tags)
+					}
 				}
 				return nil
 			}
 
+			var str string
 			if args[0] == `all` {
-				str, err := os.ReadFile(filepath.Join(keg.Path, `dex`, `tags`))
+				buf, err := os.ReadFile(filepath.Join(keg.Path, `dex`, `tags`))
 				if err != nil {
 					return err
 				}
-				fmt.Print(string(str))
-				return nil
+				str = string(buf)
+			} else {
+				str, err = GrepTags(keg.Path, args[0])
+				if err != nil {
+					return err
+				}
+			}
+			return printTags(x.Caller, str)
+		}
+
+		keg, id, entry, err := get(x, args[1])
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return fmt.Errorf(_NodeNotFound, args[1])
+		}
+
+		if err := Tag(keg.Path, id, args[0]); err != nil {
+			return err
+		}
+
+		return publish(x.Caller, keg.Path,
+			&Change{Op: OpTagged, Nodes: Dex{entry}, Note: args[0]})
+	},
+}
+
+// printTags prints the lines from a dex/tags file in the output format
+// (see outputFormat). For FormatMD and FormatPretty the lines are
+// printed as is.
+func printTags(x *Z.Cmd, lines string) error {
+	f, err := outputFormat(x)
+	if err != nil {
+		return err
+	}
+	tmap := TagsMap{}
+	if err := tmap.UnmarshalText([]byte(lines)); err != nil {
+		return err
+	}
+	switch f {
+	case FormatJSON:
+		buf, _ := tmap.MarshalJSON()
+		fmt.Println(string(buf))
+	case FormatTSV:
+		fmt.Print(tmap.TSV())
+	default:
+		fmt.Print(lines)
+	}
+	return nil
+}
+
+var publishCmd = &Z.Cmd{
+	Name:        `publish`,
+	Aliases:     []string{`pub`},
+	Usage:       `[help|dry-run]`,
+	Params:      []string{`dry-run`, `--dry-run`},
+	MaxArgs:     1,
+	Summary:     help.S(_publish),
+	Description: help.D(_publish),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+
+		keg, err := current(x.Caller)
+		if err != nil {
+			return err
+		}
+
+		conf := publishConf(x.Caller, keg.Path)
+
+		if len(args) > 0 && (args[0] == `dry-run` || args[0] == `--dry-run`) {
+			out, err := conf.DryRun(keg.Path)
+			if err != nil {
+				return err
+			}
+			fmt.Print(out)
+			return nil
+		}
+
+		warnAssets(conf, keg.Path)
+		return conf.Publish(keg.Path, nil, true)
+	},
+}
+
+var syncCmd = &Z.Cmd{
+	Name:        `sync`,
+	Usage:       `[help]`,
+	MaxArgs:     0,
+	Summary:     help.S(_sync),
+	Description: help.D(_sync),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, _ ...string) error {
+
+		keg, err := current(x.Caller)
+		if err != nil {
+			return err
+		}
+
+		conf := publishConf(x.Caller, keg.Path)
+
+		resolved, err := Sync(keg.Path, conf.Mode == PublishPush)
+		for _, path := range resolved {
+			log.Println("✔", path)
+		}
+		return err
+	},
+}
+
+var watchCmd = &Z.Cmd{
+	Name:        `watch`,
+	Usage:       `[help|INTERVAL]`,
+	MaxArgs:     1,
+	Summary:     help.S(_watch),
+	Description: help.D(_watch),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+
+		keg, err := current(x.Caller)
+		if err != nil {
+			return err
+		}
+
+		var every time.Duration
+		if len(args) > 0 {
+			every, err = time.ParseDuration(args[0])
+			if err != nil || every <= 0 {
+				return fmt.Errorf(_BadInterval, args[0])
+			}
+		}
+
+		conf := publishConf(x.Caller, keg.Path)
+		pub := func() error { return conf.Publish(keg.Path, nil, true) }
+
+		done := make(chan struct{})
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() { <-sig; close(done) }()
+
+		log.Println("watching", keg.Path)
+		updated := func(e *DexEntry) { warnDupes(x.Caller, keg.Path, Dex{e}) }
+		if err := Watch(keg.Path, done, updated, pub, every); err != nil {
+			return err
+		}
+		if every > 0 {
+			return pub()
+		}
+		return nil
+	},
+}
+
+var mapCmd = &Z.Cmd{
+	Name:        `map`,
+	Aliases:     []string{`kegs`},
+	Summary:     help.S(_map),
+	Description: help.D(_map),
+	Commands:    []*Z.Cmd{help.Cmd, mapListCmd, mapAddCmd, mapRmCmd},
+}
+
+var mapListCmd = &Z.Cmd{
+	Name:        `list`,
+	Aliases:     []string{`ls`},
+	NoArgs:      true,
+	Summary:     help.S(_map_list),
+	Description: help.D(_map_list),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, _ ...string) error {
+		root := x.Caller.Caller // keg map list
+		data, err := root.C(`map`)
+		if err != nil {
+			return err
+		}
+		list, err := ReadMap(data)
+		if err != nil {
+			return err
+		}
+		cur, _ := root.Get(`current`)
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, e := range list {
+			mark := ` `
+			if e.Name == cur {
+				mark = `*`
 			}
+			updated, title := `-`, _MapNoKeg
+			if info, err := ReadKegInfo(e.Dir); err == nil {
+				updated, title = info.Updated, info.Title
+			}
+			fmt.Fprintf(w, "%v %v\t%v\t%v\t%v\n", mark, e.Name, updated, title, e.Path)
+		}
+		return w.Flush()
+	},
+}
+
+var mapAddCmd = &Z.Cmd{
+	Name:        `add`,
+	Aliases:     []string{`set`},
+	Usage:       `(help|NAME PATH)`,
+	NumArgs:     2,
+	Summary:     help.S(_map_add),
+	Description: help.D(_map_add),
+	Commands:    []*Z.Cmd{help.Cmd},
 
-			str, err := GrepTags(keg.Path, args[0])
+	Call: func(x *Z.Cmd, args ...string) error {
+		root := x.Caller.Caller // keg map add
+		name, path := args[0], args[1]
+		if strings.ContainsAny(name, `./ `) {
+			return fmt.Errorf(_BadKegName, name)
+		}
+		if !strings.HasPrefix(path, `~`) {
+			abs, err := filepath.Abs(path)
 			if err != nil {
 				return err
 			}
-			fmt.Print(str)
+			path = abs
+		}
+		dir := fs.Tilde2Home(path)
+		if fs.NotExists(filepath.Join(dir, `keg`)) &&
+			fs.NotExists(filepath.Join(dir, `docs`, `keg`)) {
+			return fmt.Errorf(_NotKegDir, path)
+		}
+		data, _ := Z.Conf.Data()
+		doc, err := yamlSet(data, confKeys(root.Path(), `map`, name), path)
+		if err != nil {
+			return err
+		}
+		return Z.Conf.OverWrite(doc)
+	},
+}
+
+var mapRmCmd = &Z.Cmd{
+	Name:        `rm`,
+	Aliases:     []string{`remove`, `delete`},
+	Usage:       `(help|NAME)`,
+	NumArgs:     1,
+	Summary:     help.S(_map_rm),
+	Description: help.D(_map_rm),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		root := x.Caller.Caller // keg map rm
+		data, err := Z.Conf.Data()
+		if err != nil {
+			return err
+		}
+		doc, found, err := yamlDel(data, confKeys(root.Path(), `map`, args[0]))
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf(_KegNotMapped, args[0])
+		}
+		if err := Z.Conf.OverWrite(doc); err != nil {
+			return err
+		}
+		if cur, _ := root.Get(`current`); cur == args[0] {
+			return root.Del(`current`)
+		}
+		return nil
+	},
+}
+
+var useCmd = &Z.Cmd{
+	Name:        `use`,
+	Usage:       `(help|NAME)`,
+	NumArgs:     1,
+	Summary:     help.S(_use),
+	Description: help.D(_use),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		name := args[0]
+		if name == "" {
+			return fmt.Errorf(_NoKegName)
+		}
+		if !(name[0] == os.PathSeparator || name[0] == '~') {
+			if dir, _ := x.Caller.C(`map.` + name); dir == "" || dir == "null" {
+				return fmt.Errorf(_KegNotMapped, name)
+			}
+		}
+		return x.Caller.Set(`current`, name)
+	},
+}
+
+var allCmd = &Z.Cmd{
+	Name:        `all`,
+	Summary:     help.S(_all),
+	Description: help.D(_all),
+	Commands:    []*Z.Cmd{help.Cmd, allTitlesCmd, allGrepCmd, allChangesCmd},
+}
+
+// printAll prints the KegDex in the output format (see outputFormat).
+// FormatMD is an include list with cross-keg links.
+func printAll(x *Z.Cmd, d KegDex, page bool) error {
+	f, err := outputFormat(x)
+	if err != nil {
+		return err
+	}
+	switch f {
+	case FormatJSON:
+		buf, err := d.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(buf))
+	case FormatTSV:
+		fmt.Print(d.TSV())
+	case FormatMD:
+		fmt.Print(d.AsIncludes())
+	default:
+		if page {
+			Z.Page(d.Pretty())
 			return nil
 		}
+		fmt.Print(d.Pretty())
+	}
+	return nil
+}
 
-		keg, id, _, err := get(x, args[1])
+// regexpArg compiles the regular expression argument with the regxpre
+// var prefix (or the default given) added.
+func regexpArg(x *Z.Cmd, arg, def string) (*regexp.Regexp, error) {
+	pre, err := x.Get(`regxpre`)
+	if err != nil {
+		return nil, err
+	}
+	if pre == "" || pre == "null" {
+		pre = def
+	}
+	return regexp.Compile(pre + arg)
+}
 
-		return Tag(keg.Path, id, args[0])
+var allTitlesCmd = &Z.Cmd{
+	Name:        `titles`,
+	Aliases:     []string{`title`},
+	Usage:       `(help|REGEXP)`,
+	Summary:     help.S(_all_titles),
+	Description: help.D(_all_titles),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		if len(args) == 0 {
+			args = append(args, "")
+		}
+		root := x.Caller.Caller // keg all titles
+		re, err := regexpArg(root, args[0], `(?i)`)
+		if err != nil {
+			return err
+		}
+		return printAll(root, AllDex(kegMap(root), TitleFilter(re)), true)
+	},
+}
+
+var allGrepCmd = &Z.Cmd{
+	Name:        `grep`,
+	Usage:       `(help|REGEXP)`,
+	MinArgs:     1,
+	Summary:     help.S(_all_grep),
+	Description: help.D(_all_grep),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		root := x.Caller.Caller // keg all grep
+		re, err := regexpArg(root, args[0], ``)
+		if err != nil {
+			return err
+		}
+		return printAll(root, AllDex(kegMap(root), GrepFilter(re)), true)
+	},
+}
+
+var allChangesCmd = &Z.Cmd{
+	Name:        `changes`,
+	Aliases:     []string{`changed`},
+	Usage:       `[help|COUNT]`,
+	MaxArgs:     1,
+	Summary:     help.S(_all_changes),
+	Description: help.D(_all_changes),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Dynamic: template.FuncMap{
+		`changesdef`: func() int { return ChangesDefault },
+	},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		n := ChangesDefault
+		if len(args) > 0 {
+			i, err := strconv.Atoi(args[0])
+			if err != nil || i <= 0 {
+				return fmt.Errorf(_InvalidCount, args[0])
+			}
+			n = i
+		}
+		root := x.Caller.Caller // keg all changes
+		all := AllDex(kegMap(root), nil)
+		if len(all) > n {
+			all = all[:n]
+		}
+		return printAll(root, all, false)
+	},
+}
+
+var sendCmd = &Z.Cmd{
+	Name:        `send`,
+	Aliases:     []string{`move`, `mv`},
+	Usage:       `(help|(ID|same|last|REGEXP) KEG [copy|stub])`,
+	MinArgs:     2,
+	MaxArgs:     3,
+	Summary:     help.S(_send),
+	Description: help.D(_send),
+	Commands:    []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, args ...string) error {
+
+		from, _, entry, err := get(x, args[0])
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return fmt.Errorf(_NodeNotFound, args[0])
+		}
+
+		to, err := mapped(x.Caller, args[1])
+		if err != nil {
+			return err
+		}
+
+		mode := SendMove
+		if len(args) > 2 {
+			mode = strings.TrimPrefix(args[2], `--`)
+		}
+
+		sent, err := Send(from, to, entry.N, mode)
+		if err != nil {
+			return err
+		}
+		warnDupes(x.Caller, to.Path, Dex{sent})
+		term.Print(Link{Keg: to.Name, N: sent.N})
+
+		err = publish(x.Caller, to.Path, &Change{
+			Op:    OpReceived,
+			Nodes: Dex{sent},
+			Note:  `from ` + Link{Keg: from.Name, N: entry.N}.String(),
+		})
+		if err != nil || mode == SendCopy {
+			return err
+		}
+		return publish(x.Caller, from.Path, &Change{
+			Op:    OpSent,
+			Nodes: Dex{entry},
+			Note:  `to ` + Link{Keg: to.Name, N: sent.N}.String(),
+		})
 	},
 }
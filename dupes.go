@@ -0,0 +1,304 @@
+package keg
+
+import (
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	DupeSame = `same` // titles the same when normalized (see NormTitle)
+	DupeNear = `near` // titles within DupeTitleDistance edits
+	DupeBody = `body` // bodies at least DupeBodySimilarity alike
+)
+
+// DupeTitleDistance is the most edits (see Distance) between normalized
+// titles for them to be reported as near duplicates. Fewer are allowed
+// for short titles so that at least four fifths of the shorter one must
+// be unchanged ("Go" and "Git" are not near duplicates).
+var DupeTitleDistance = 2
+
+// DupeBodySimilarity is the least similarity (Jaccard index of the sets
+// of DupeShingle word sequences) between the bodies of two nodes for
+// them to be reported as duplicates.
+var DupeBodySimilarity = 0.5
+
+// DupeShingle is the number of words in each of the overlapping word
+// sequences (shingles) that node bodies are compared by. Bodies with
+// fewer words are never compared.
+var DupeShingle = 4
+
+// dupeCommon is the most nodes a shingle (or title trigram) may be
+// found in and still be used to find candidates. More common ones (such
+// as "of the") say nothing about similarity and only slow things down.
+const dupeCommon = 100
+
+// Dupe is a pair of nodes that have the same or nearly the same title
+// or similar bodies.
+type Dupe struct {
+	Kind  string  // DupeSame, DupeNear, or DupeBody
+	A, B  int     // node IDs (A < B)
+	Score float64 // edit distance of titles or similarity of bodies
+}
+
+// NormTitle returns the title in lowercase with everything but letters
+// and digits (punctuation, markup, and extra spaces) removed except for
+// single spaces between words.
+func NormTitle(title string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(title),
+		func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }), ` `)
+}
+
+// Distance returns the Levenshtein edit distance between a and b (the
+// number of runes that must be inserted, deleted, or changed to turn
+// one into the other).
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// Shingles returns the set of overlapping sequences of size words
+// (normalized as with NormTitle) within the text.
+func Shingles(text string, size int) map[string]bool {
+	words := strings.Fields(NormTitle(text))
+	set := map[string]bool{}
+	for i := 0; i+size <= len(words); i++ {
+		set[strings.Join(words[i:i+size], ` `)] = true
+	}
+	return set
+}
+
+// Similarity returns the Jaccard index of the two sets (the size of
+// their intersection divided by that of their union), 0 if both are
+// empty.
+func Similarity(a, b map[string]bool) float64 {
+	var both int
+	for s := range a {
+		if b[s] {
+			both++
+		}
+	}
+	if all := len(a) + len(b) - both; all > 0 {
+		return float64(both) / float64(all)
+	}
+	return 0
+}
+
+// nearTitles returns true if the normalized titles a and b are within
+// DupeTitleDistance edits (fewer for short titles) returning the
+// distance as well.
+func nearTitles(a, b string) (int, bool) {
+	short := len([]rune(a))
+	if n := len([]rune(b)); n < short {
+		short = n
+	}
+	limit := DupeTitleDistance
+	if short/5 < limit {
+		limit = short / 5
+	}
+	diff := len([]rune(a)) - len([]rune(b))
+	if diff > limit || -diff > limit {
+		return 0, false
+	}
+	d := Distance(a, b)
+	return d, d <= limit
+}
+
+// TitleDupes returns the other entries of the Dex with the same or
+// nearly the same title as the entry (see NormTitle and
+// DupeTitleDistance). Those that are the same come first. Titles without
+// any letters or digits are never duplicates.
+func (d Dex) TitleDupes(entry *DexEntry) Dex {
+	norm := NormTitle(entry.T)
+	var same, near Dex
+	if norm == "" {
+		return nil
+	}
+	for _, e := range d {
+		if e.N == entry.N {
+			continue
+		}
+		other := NormTitle(e.T)
+		if other == norm {
+			same = append(same, e)
+			continue
+		}
+		if _, ok := nearTitles(norm, other); ok {
+			near = append(near, e)
+		}
+	}
+	return append(same, near...)
+}
+
+// candidates returns every pair of IDs (lowest first) that have at
+// least one token in common ignoring tokens common to more than
+// dupeCommon IDs.
+func candidates(tokens map[int]map[string]bool) map[[2]int]bool {
+	index := map[string][]int{}
+	for id, set := range tokens {
+		for t := range set {
+			index[t] = append(index[t], id)
+		}
+	}
+	pairs := map[[2]int]bool{}
+	for _, ids := range index {
+		if len(ids) > dupeCommon {
+			continue
+		}
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				a, b := ids[i], ids[j]
+				if a > b {
+					a, b = b, a
+				}
+				pairs[[2]int{a, b}] = true
+			}
+		}
+	}
+	return pairs
+}
+
+// dupeSketch is the number of shingles of each body used to find
+// candidates (see sketch).
+const dupeSketch = 8
+
+// sketch returns the (at most) size members of the set with the lowest
+// hashes. Two sets that are similar are very likely to have at least one
+// of these in common (which is the idea behind MinHash) so comparing
+// only the pairs that do is much faster than comparing all of them and
+// rarely misses any.
+func sketch(set map[string]bool, size int) map[string]bool {
+	type hashed struct {
+		s string
+		h uint64
+	}
+	all := make([]hashed, 0, len(set))
+	for s := range set {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		all = append(all, hashed{s, h.Sum64()})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].h < all[j].h })
+	if len(all) > size {
+		all = all[:size]
+	}
+	low := map[string]bool{}
+	for _, a := range all {
+		low[a.s] = true
+	}
+	return low
+}
+
+// Dupes returns every pair of nodes of the keg at kegpath with the same
+// normalized title (DupeSame), nearly the same title (DupeNear), or
+// similar bodies (DupeBody, the text after the title line), sorted by
+// kind in that order and then by ID. Titles without any letters or
+// digits are ignored. A pair with the same or near
+// titles is not reported again for similar bodies. To keep this fast
+// for large kegs only pairs with at least one uncommon title trigram
+// (or body shingle from those sketched, see sketch) in common are
+// compared.
+func Dupes(kegpath string) ([]Dupe, error) {
+	dex, err := ReadDex(kegpath)
+	if err != nil {
+		return nil, err
+	}
+	var dupes []Dupe
+	found := map[[2]int]bool{}
+
+	titles := map[int]string{}
+	trigrams := map[int]map[string]bool{}
+	byTitle := map[string][]int{}
+	for _, e := range *dex {
+		t := NormTitle(e.T)
+		titles[e.N] = t
+		byTitle[t] = append(byTitle[t], e.N)
+		runes := []rune(` ` + t + ` `)
+		set := map[string]bool{}
+		for i := 0; i+3 <= len(runes); i++ {
+			set[string(runes[i:i+3])] = true
+		}
+		trigrams[e.N] = set
+	}
+	for t, ids := range byTitle {
+		if t == "" {
+			continue
+		}
+		sort.Ints(ids)
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				found[[2]int{ids[i], ids[j]}] = true
+				dupes = append(dupes, Dupe{DupeSame, ids[i], ids[j], 0})
+			}
+		}
+	}
+	for pair := range candidates(trigrams) {
+		if found[pair] {
+			continue
+		}
+		if d, ok := nearTitles(titles[pair[0]], titles[pair[1]]); ok {
+			found[pair] = true
+			dupes = append(dupes, Dupe{DupeNear, pair[0], pair[1], float64(d)})
+		}
+	}
+
+	shingles := map[int]map[string]bool{}
+	sketches := map[int]map[string]bool{}
+	for _, e := range *dex {
+		buf, err := os.ReadFile(filepath.Join(kegpath, e.ID(), `README.md`))
+		if err != nil {
+			continue
+		}
+		_, body, _ := strings.Cut(string(buf), "\n")
+		if set := Shingles(body, DupeShingle); len(set) > 0 {
+			shingles[e.N] = set
+			sketches[e.N] = sketch(set, dupeSketch)
+		}
+	}
+	for pair := range candidates(sketches) {
+		if found[pair] {
+			continue
+		}
+		if s := Similarity(shingles[pair[0]], shingles[pair[1]]); s >= DupeBodySimilarity {
+			dupes = append(dupes, Dupe{DupeBody, pair[0], pair[1], s})
+		}
+	}
+
+	order := map[string]int{DupeSame: 0, DupeNear: 1, DupeBody: 2}
+	sort.Slice(dupes, func(i, j int) bool {
+		a, b := dupes[i], dupes[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		if a.A != b.A {
+			return a.A < b.A
+		}
+		return a.B < b.B
+	})
+	return dupes, nil
+}
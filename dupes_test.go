@@ -0,0 +1,87 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleNormTitle() {
+	fmt.Println(keg.NormTitle("  Installing **Go** (on Linux)!  "))
+
+	// Output:
+	// installing go on linux
+}
+
+func ExampleDistance() {
+	fmt.Println(keg.Distance(`kitten`, `sitting`))
+	fmt.Println(keg.Distance(`über`, `uber`))
+	fmt.Println(keg.Distance(``, `go`))
+
+	// Output:
+	// 3
+	// 1
+	// 2
+}
+
+func ExampleSimilarity() {
+	a := keg.Shingles(`the quick brown fox jumps`, 2)
+	b := keg.Shingles(`The quick brown fox sleeps.`, 2)
+	fmt.Println(len(a), keg.Similarity(a, b))
+
+	// Output:
+	// 4 0.6
+}
+
+func ExampleDex_TitleDupes() {
+	dex := keg.Dex{
+		&keg.DexEntry{N: 1, T: `Installing Go on Linux`},
+		&keg.DexEntry{N: 2, T: `Installing Go in Linux`},
+		&keg.DexEntry{N: 3, T: `installing go on linux.`},
+		&keg.DexEntry{N: 4, T: `Go`},
+		&keg.DexEntry{N: 5, T: `Git`},
+	}
+	for _, e := range dex.TitleDupes(dex[0]) {
+		fmt.Println(e.N, e.T)
+	}
+	fmt.Println(len(dex.TitleDupes(dex[3])))
+
+	// Output:
+	// 3 installing go on linux.
+	// 2 Installing Go in Linux
+	// 0
+}
+
+func ExampleDupes() {
+	dir, _ := os.MkdirTemp("", "kegdupes")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, `keg`), []byte("updated:\n"), 0644)
+	body := "\n\nThe quick brown fox jumps over the lazy dog and then " +
+		"runs away into the forest where nobody can find it again.\n"
+	for i, node := range []string{
+		"# Installing Go" + body,
+		"# Something else entirely" + body + "Except for this.\n",
+		"# installing go!\n\nDifferent.\n",
+		"# Installing Git\n\nAlso different.\n",
+		"# Installing Go now\n\nStill different.\n",
+	} {
+		os.Mkdir(filepath.Join(dir, fmt.Sprint(i+1)), 0700)
+		os.WriteFile(filepath.Join(dir, fmt.Sprint(i+1), `README.md`), []byte(node), 0644)
+	}
+	keg.MakeDex(dir)
+
+	dupes, err := keg.Dupes(dir)
+	for _, d := range dupes {
+		fmt.Printf("%v %v %v %.2f\n", d.Kind, d.A, d.B, d.Score)
+	}
+	fmt.Println(err)
+
+	// Output:
+	// same 1 3 0.00
+	// near 1 4 2.00
+	// near 3 4 2.00
+	// body 1 2 0.86
+	// <nil>
+}
@@ -0,0 +1,132 @@
+package keg
+
+import (
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultFeedCount is the number of entries in the Atom feed when not
+// set in the feed section of the keg file.
+var DefaultFeedCount = 20
+
+// FeedConf is the feed section of the keg file. When present an Atom
+// feed of the latest changes is written to dex/feed.xml every time the
+// dex is (see WriteDex). Use "feed: {}" to accept the defaults.
+//
+//	feed:
+//	  count: 50
+type FeedConf struct {
+	Count int `yaml:"count"` // number of entries (default DefaultFeedCount)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    *atomLink   `xml:"link,omitempty"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+}
+
+// Atom renders the Dex as an Atom feed of the latest changes (sorted
+// ByChanges) using the title, url, creator, and linkfmt of the keg
+// info. Only as many entries as the feed Count (or DefaultFeedCount)
+// are included. Entry links are made from the linkfmt (see Link.URL).
+// If there is no linkfmt the links are relative to the dex directory
+// (../ID) which works when the keg is published to the Web as is.
+func (d Dex) Atom(info *KegInfo) (string, error) {
+	count := DefaultFeedCount
+	if info.Feed != nil && info.Feed.Count > 0 {
+		count = info.Feed.Count
+	}
+	changes := make(Dex, len(d))
+	copy(changes, d)
+	changes.ByChanges()
+	if len(changes) > count {
+		changes = changes[:count]
+	}
+
+	feed := atomFeed{
+		Title: info.Title,
+		ID:    feedID(info, ""),
+	}
+	if isWebURL(info.URL) {
+		feed.Link = &atomLink{Href: info.URL}
+	}
+	if info.Creator != "" {
+		feed.Author = &atomAuthor{Name: info.Creator}
+		if isWebURL(info.Creator) {
+			feed.Author.URI = info.Creator
+		}
+	}
+	if len(changes) > 0 {
+		feed.Updated = changes[0].U.UTC().Format(time.RFC3339)
+	} else {
+		feed.Updated = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	for _, e := range changes {
+		link := `../` + e.ID()
+		if strings.Contains(info.LinkFmt, `{{id}}`) {
+			link = strings.Replace(info.LinkFmt, `{{id}}`, e.ID(), 1)
+		}
+		id := link
+		if !isWebURL(link) {
+			id = feedID(info, e.ID())
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   e.T,
+			ID:      id,
+			Updated: e.U.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: link},
+		})
+	}
+
+	buf, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(buf) + "\n", nil
+}
+
+// feedID returns a unique identifier for the keg (or node within it if
+// id is not empty) based on its URL (or title if it has none) for use
+// where a Web URL is not available.
+func feedID(info *KegInfo, id string) string {
+	name := info.URL
+	if name == "" {
+		name = info.Title
+	}
+	if isWebURL(name) && id == "" {
+		return name
+	}
+	urn := `urn:keg:` + url.PathEscape(name)
+	if id != "" {
+		urn += `:` + id
+	}
+	return urn
+}
+
+// isWebURL returns true if the string is an absolute http(s) URL.
+func isWebURL(s string) bool {
+	return strings.HasPrefix(s, `https://`) || strings.HasPrefix(s, `http://`)
+}
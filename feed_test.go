@@ -0,0 +1,51 @@
+package keg_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleDex_Atom() {
+	t := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	dex := keg.Dex{
+		&keg.DexEntry{U: t, T: `Older`, N: 1},
+		&keg.DexEntry{U: t.Add(time.Hour), T: `Newer & better`, N: 2},
+		&keg.DexEntry{U: t.Add(-time.Hour), T: `Oldest`, N: 3},
+	}
+	info := &keg.KegInfo{
+		Title:   `My Keg`,
+		URL:     `https://example.com/keg`,
+		Creator: `https://example.com`,
+		LinkFmt: `https://example.com/keg/{{id}}`,
+		Feed:    &keg.FeedConf{Count: 2},
+	}
+	feed, _ := dex.Atom(info)
+	fmt.Print(feed)
+
+	// Output:
+	// <?xml version="1.0" encoding="UTF-8"?>
+	// <feed xmlns="http://www.w3.org/2005/Atom">
+	//   <title>My Keg</title>
+	//   <id>https://example.com/keg</id>
+	//   <updated>2023-01-02T04:04:05Z</updated>
+	//   <link href="https://example.com/keg"></link>
+	//   <author>
+	//     <name>https://example.com</name>
+	//     <uri>https://example.com</uri>
+	//   </author>
+	//   <entry>
+	//     <title>Newer &amp; better</title>
+	//     <id>https://example.com/keg/2</id>
+	//     <updated>2023-01-02T04:04:05Z</updated>
+	//     <link href="https://example.com/keg/2"></link>
+	//   </entry>
+	//   <entry>
+	//     <title>Older</title>
+	//     <id>https://example.com/keg/1</id>
+	//     <updated>2023-01-02T03:04:05Z</updated>
+	//     <link href="https://example.com/keg/1"></link>
+	//   </entry>
+	// </feed>
+}
@@ -4,6 +4,7 @@ go 1.18
 
 require (
 	github.com/charmbracelet/glamour v0.6.0
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/rwxrob/bonzai v0.20.10
 	github.com/rwxrob/choose v0.2.1
 	github.com/rwxrob/conf v0.8.2
@@ -15,6 +16,7 @@ require (
 	github.com/rwxrob/term v0.2.9
 	github.com/rwxrob/to v0.12.1
 	github.com/rwxrob/vars v0.6.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -57,5 +59,4 @@ require (
 	golang.org/x/text v0.5.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
 	gopkg.in/op/go-logging.v1 v1.0.0-20160211212156-b2cb9fa56473 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
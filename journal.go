@@ -0,0 +1,145 @@
+package keg
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rwxrob/fs/file"
+)
+
+// DefaultJournalTitle is the Go time layout for the titles of journal
+// (daily) nodes when not set in the journal section of the keg file.
+var DefaultJournalTitle = `2006-01-02`
+
+// DefaultJournalStamp is the Go time layout for the heading of each
+// entry appended to a journal node when not set in the journal section
+// of the keg file.
+var DefaultJournalStamp = `15:04`
+
+// JournalConf is the journal section of the keg file. When present (or
+// when dex/journal.md exists, see Today) an include list of all the
+// journal nodes, newest first, is written to dex/journal.md every time
+// the dex is (see WriteDex). Use "journal: {}" to accept the defaults.
+//
+//	journal:
+//	  title: Monday, January 2, 2006
+//	  stamp: 3:04 PM
+type JournalConf struct {
+	Title string `yaml:"title"` // layout of node titles
+	Stamp string `yaml:"stamp"` // layout of entry headings
+}
+
+// TitleFmt returns the Title layout or DefaultJournalTitle. It is safe
+// to call on nil.
+func (c *JournalConf) TitleFmt() string {
+	if c == nil || c.Title == "" {
+		return DefaultJournalTitle
+	}
+	return c.Title
+}
+
+// StampFmt returns the Stamp layout or DefaultJournalStamp. It is safe
+// to call on nil.
+func (c *JournalConf) StampFmt() string {
+	if c == nil || c.Stamp == "" {
+		return DefaultJournalStamp
+	}
+	return c.Stamp
+}
+
+// Journal returns the journal nodes of the Dex (those with a title that
+// is a date in the layout) sorted by that date, newest first.
+func (d Dex) Journal(layout string) Dex {
+	type dated struct {
+		e *DexEntry
+		t time.Time
+	}
+	var days []dated
+	for _, e := range d {
+		if t, err := time.Parse(layout, e.T); err == nil {
+			days = append(days, dated{e, t})
+		}
+	}
+	sort.SliceStable(days, func(i, j int) bool { return days[i].t.After(days[j].t) })
+	journal := Dex{}
+	for _, day := range days {
+		journal = append(journal, day.e)
+	}
+	return journal
+}
+
+// Today returns the journal node of the keg at kegpath for the day of
+// now (in its own location) creating it if there is none yet. A new
+// node only has the title line and is not yet in the dex (see
+// DexUpdate). The dex/journal.md file is created (empty) if it does not
+// yet exist so that the index of journal nodes is kept from then on.
+func Today(kegpath string, conf *JournalConf, now time.Time) (entry *DexEntry, created bool, err error) {
+	journal := filepath.Join(kegpath, `dex`, `journal.md`)
+	if !file.Exists(journal) {
+		if err := file.Touch(journal); err != nil {
+			return nil, false, err
+		}
+	}
+	title := now.Format(conf.TitleFmt())
+	if dex, err := ReadDex(kegpath); err == nil {
+		for _, e := range *dex {
+			if e.T == title {
+				return e, false, nil
+			}
+		}
+	}
+	entry, err = MakeNode(kegpath)
+	if err != nil {
+		return nil, false, err
+	}
+	path := filepath.Join(kegpath, entry.ID(), `README.md`)
+	if err := file.Overwrite(path, `# `+title+"\n"); err != nil {
+		os.RemoveAll(filepath.Dir(path))
+		return nil, false, err
+	}
+	entry.T = title
+	return entry, true, nil
+}
+
+// AppendJournal appends an entry with a heading for the time of now
+// (see JournalConf.StampFmt) followed by the text (if any) to the end
+// of the body of the node README.md file but before any footnotes (see
+// AppendText).
+func AppendJournal(kegpath string, id int, conf *JournalConf, now time.Time, text string) error {
+	path := filepath.Join(kegpath, (&DexEntry{N: id}).ID(), `README.md`)
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	add := "## " + now.Format(conf.StampFmt())
+	if text = strings.TrimSpace(text); text != "" {
+		add += "\n\n" + text
+	}
+	return file.Overwrite(path, AppendText(string(buf), add, false))
+}
+
+// TrimJournal removes the heading for the time of now (see
+// AppendJournal) from the end of the body of the node README.md file
+// if nothing has been written after it (as when the entry is left
+// empty in the editor) returning true if it did. Any footnotes are kept.
+func TrimJournal(kegpath string, id int, conf *JournalConf, now time.Time) (bool, error) {
+	path := filepath.Join(kegpath, (&DexEntry{N: id}).ID(), `README.md`)
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	body, foot := splitFootnotes(string(buf))
+	text := strings.TrimRight(body, " \t\n")
+	rest := strings.TrimSuffix(text, "## "+now.Format(conf.StampFmt()))
+	if rest == text || !strings.HasSuffix(rest, "\n") {
+		return false, nil
+	}
+	out := strings.TrimRight(rest, "\n") + "\n"
+	if foot != "" {
+		out += "\n" + foot
+	}
+	return true, file.Overwrite(path, out)
+}
@@ -0,0 +1,98 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleDex_Journal() {
+	dex := keg.Dex{
+		&keg.DexEntry{N: 1, T: `2023-01-02`},
+		&keg.DexEntry{N: 2, T: `Not a journal node`},
+		&keg.DexEntry{N: 3, T: `2023-01-10`},
+		&keg.DexEntry{N: 4, T: `2022-12-31`},
+	}
+	fmt.Print(dex.Journal(keg.DefaultJournalTitle).AsIncludes())
+	// Output:
+	// * [2023-01-10](../3)
+	// * [2023-01-02](../1)
+	// * [2022-12-31](../4)
+}
+
+func ExampleToday() {
+	dir, _ := os.MkdirTemp("", "kegtoday")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, `keg`), []byte("updated:\n"), 0644)
+	os.Mkdir(filepath.Join(dir, `1`), 0700)
+	os.WriteFile(filepath.Join(dir, `1`, `README.md`), []byte("# Other\n"), 0644)
+	keg.MakeDex(dir)
+
+	conf := &keg.JournalConf{Stamp: `3:04 PM`}
+	now := time.Date(2023, 1, 2, 15, 4, 0, 0, time.UTC)
+	for _, text := range []string{`First`, `Second`} {
+		entry, created, _ := keg.Today(dir, conf, now)
+		fmt.Println(entry.N, entry.T, created)
+		keg.AppendJournal(dir, entry.N, conf, now, text)
+		keg.DexUpdate(dir, entry)
+	}
+	buf, _ := os.ReadFile(filepath.Join(dir, `2`, `README.md`))
+	fmt.Print(string(buf))
+	buf, _ = os.ReadFile(filepath.Join(dir, `dex`, `journal.md`))
+	fmt.Print(string(buf))
+
+	// Output:
+	// 2 2023-01-02 true
+	// 2 2023-01-02 false
+	// # 2023-01-02
+	//
+	// ## 3:04 PM
+	//
+	// First
+	//
+	// ## 3:04 PM
+	//
+	// Second
+	// * [2023-01-02](../2)
+}
+
+func ExampleTrimJournal() {
+	dir, _ := os.MkdirTemp("", "kegtrim")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, `1`), 0700)
+	readme := filepath.Join(dir, `1`, `README.md`)
+	os.WriteFile(readme, []byte("# 2023-01-02\n\n## 15:00\n\nFirst[^1]\n\n[^1]: A note.\n"), 0644)
+
+	conf := &keg.JournalConf{}
+	now := time.Date(2023, 1, 2, 15, 4, 0, 0, time.UTC)
+	keg.AppendJournal(dir, 1, conf, now, "")
+	buf, _ := os.ReadFile(readme)
+	fmt.Print(string(buf))
+	fmt.Println(keg.TrimJournal(dir, 1, conf, now))
+	fmt.Println(keg.TrimJournal(dir, 1, conf, now))
+	buf, _ = os.ReadFile(readme)
+	fmt.Print(string(buf))
+
+	// Output:
+	// # 2023-01-02
+	//
+	// ## 15:00
+	//
+	// First[^1]
+	//
+	// ## 15:04
+	//
+	// [^1]: A note.
+	// true <nil>
+	// false <nil>
+	// # 2023-01-02
+	//
+	// ## 15:00
+	//
+	// First[^1]
+	//
+	// [^1]: A note.
+}
@@ -3,25 +3,27 @@ package keg
 import (
 	"bufio"
 	_ "embed"
+	"errors"
 	"fmt"
+	iofs "io/fs"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	Z "github.com/rwxrob/bonzai/z"
 	"github.com/rwxrob/fs"
 	_fs "github.com/rwxrob/fs"
 	"github.com/rwxrob/fs/dir"
 	"github.com/rwxrob/fs/file"
 	"github.com/rwxrob/keg/kegml"
-	"github.com/rwxrob/term"
 	"github.com/rwxrob/to"
+	"gopkg.in/yaml.v3"
 )
 
 // NodePaths returns a list of node directory paths contained in the
@@ -37,83 +39,274 @@ import (
 // ignored.
 var NodePaths = _fs.IntDirs
 
+// LatestDexEntryExp matches a single entry of the dex/changes.md file.
+//
+// Deprecated: Use kegml.ParseDexEntry instead, which also handles titles
+// containing "](../" and is what ParseDex and ChangesScanner use.
 var LatestDexEntryExp = regexp.MustCompile(
 	`^\* (\d\d\d\d-\d\d-\d\d \d\d:\d\d:\d\dZ) \[(.*)\]\(\.\./(\d+)\)$`,
 )
 
-// ParseDex parses any input valid for to.String into a Dex pointer.
-// FIXME: replace regular expression with pegn.Scanner instead
+// ParseDex parses any input valid for to.String in the form of the
+// dex/changes.md file into a Dex pointer ignoring any KEGML content
+// before or after the list of entries (see ParseChanges).
 func ParseDex(in any) (*Dex, error) {
+	_, dex, _, err := ParseChanges(in)
+	return dex, err
+}
+
+// ParseChanges parses any input valid for to.String in the form of the
+// dex/changes.md file returning the entries along with any other KEGML
+// content (headings, paragraphs, other lists) that comes before (head)
+// and after (foot) them so that it can be preserved when the file is
+// written again (see WriteDex). The entries are the first unbroken run
+// of lines parsed by kegml.ParseDexEntry (see ChangesScanner).
+func ParseChanges(in any) (head string, dex *Dex, foot string, err error) {
+	d := Dex{}
+	c := NewChangesScanner(in)
+	for c.Scan() {
+		d = append(d, c.Entry())
+	}
+	if c.Err() != nil {
+		return "", nil, "", c.Err()
+	}
+	return c.Head(), &d, c.Foot(), nil
+}
+
+// ParseDexTSV parses any input valid for to.String in the form of the
+// dex/nodes.tsv file (ID, last change, and title separated by tabs)
+// into a Dex pointer. Blank lines and a first line that does not begin
+// with an integer ID (a header) are skipped.
+func ParseDexTSV(in any) (*Dex, error) {
 	dex := Dex{}
 	s := bufio.NewScanner(strings.NewReader(to.String(in)))
 	for line := 1; s.Scan(); line++ {
-		f := LatestDexEntryExp.FindStringSubmatch(s.Text())
-		if len(f) != 4 {
-			return nil, fmt.Errorf(_BadChangesLine, line)
+		text := s.Text()
+		if text == "" {
+			continue
 		}
-		if t, err := time.Parse(IsoDateFmt, string(f[1])); err != nil {
-			return nil, err
-		} else {
-			if i, err := strconv.Atoi(f[3]); err != nil {
-				return nil, err
-			} else {
-				dex = append(dex, &DexEntry{U: t, T: f[2], N: i})
-			}
+		f := strings.SplitN(text, "\t", 3)
+		n, err := strconv.Atoi(f[0])
+		if err != nil && line == 1 {
+			continue
+		}
+		if err != nil || len(f) != 3 {
+			return nil, fmt.Errorf(_BadNodesLine, line)
 		}
+		u, err := time.Parse(IsoDateFmt, f[1])
+		if err != nil {
+			return nil, fmt.Errorf(_BadNodesLine, line)
+		}
+		dex = append(dex, &DexEntry{U: u, T: f[2], N: n})
 	}
 	return &dex, nil
 }
 
-// ReadDex reads an existing dex/changes.md dex and returns it.
+// ReadDex reads an existing dex/changes.md dex and returns it. If there
+// is no dex/changes.md file but there is a dex/nodes.tsv file it is
+// read instead (see ReadDexTSV) and sorted ByChanges.
 func ReadDex(kegdir string) (*Dex, error) {
 	f := filepath.Join(kegdir, `dex`, `changes.md`)
 	buf, err := os.ReadFile(f)
+	if errors.Is(err, iofs.ErrNotExist) {
+		dex, terr := ReadDexTSV(kegdir)
+		if terr != nil {
+			return nil, err
+		}
+		dex.ByChanges()
+		return dex, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 	return ParseDex(buf)
 }
 
+// ReadDexTSV reads an existing dex/nodes.tsv dex and returns it (sorted
+// by ID as written).
+func ReadDexTSV(kegdir string) (*Dex, error) {
+	buf, err := os.ReadFile(filepath.Join(kegdir, `dex`, `nodes.tsv`))
+	if err != nil {
+		return nil, err
+	}
+	return ParseDexTSV(buf)
+}
+
+// readChangesText returns the KEGML content before and after the
+// entries of the existing dex/changes.md file (if any) so that it can
+// be written back around the new entries. Content that cannot be
+// parsed (or has git conflict markers, see ChangesScanner) is dropped
+// since the file is about to be replaced anyway.
+func readChangesText(kegpath string) (head, foot string) {
+	buf, err := os.ReadFile(filepath.Join(kegpath, `dex`, `changes.md`))
+	if err != nil {
+		return "", ""
+	}
+	head, _, foot, err = ParseChanges(buf)
+	if err != nil {
+		return "", ""
+	}
+	return head, foot
+}
+
+// ScanWorkers is the number of node directories ScanDex scans at the
+// same time.
+var ScanWorkers = runtime.NumCPU()
+
 // ScanDex takes the target path to a keg root directory returns a
-// Dex object.
+// Dex object sorted by time of last change (most recent first). Each
+// node directory is walked only once and several are scanned at the
+// same time (see ScanWorkers).
 func ScanDex(kegdir string) (*Dex, error) {
-	var dex Dex
+	return ScanDexSince(kegdir, nil, time.Time{})
+}
+
+// ScanDexSince is the same as ScanDex but reuses the entry from prev
+// for any node directory that has not changed since the time passed
+// rather than walking it again. A node directory is considered changed
+// if it or any file or directory directly within it has a modification
+// time after since. (Changing a file deeper within a node without
+// touching anything else in it will therefore not be noticed.) Nodes in
+// prev that no longer exist are dropped.
+func ScanDexSince(kegdir string, prev Dex, since time.Time) (*Dex, error) {
 	dirs, _, _ := NodePaths(kegdir)
-	sort.Slice(dirs, func(i, j int) bool {
-		_, iinfo := _fs.LatestChange(dirs[i].Path)
-		_, jinfo := _fs.LatestChange(dirs[j].Path)
-		return iinfo.ModTime().After(jinfo.ModTime())
-	})
-	for _, d := range dirs {
-		_, i := _fs.LatestChange(d.Path)
-		title, _ := kegml.ReadTitle(d.Path)
-		id, err := strconv.Atoi(d.Info.Name())
-		if err != nil {
-			continue
+	known := dexByID(prev)
+	scanned := make(Dex, len(dirs))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	workers := ScanWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				scanned[i] = scanNode(dirs[i], known, since)
+			}
+		}()
+	}
+	for i := range dirs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	dex := Dex{}
+	for _, entry := range scanned {
+		if entry != nil {
+			dex = append(dex, entry)
 		}
-		entry := &DexEntry{U: i.ModTime().UTC(), T: title, N: id}
-		dex = append(dex, entry)
 	}
+	sort.Slice(dex, func(i, j int) bool {
+		if dex[i].U.Equal(dex[j].U) {
+			return dex[i].N > dex[j].N
+		}
+		return dex[i].U.After(dex[j].U)
+	})
 	return &dex, nil
 }
 
+// scanNode returns a DexEntry for the node directory reusing the one
+// from known if it has not changed since the time passed. Returns nil
+// if the directory name is not a node ID or cannot be read.
+func scanNode(d _fs.PathEntry, known map[int]*DexEntry, since time.Time) *DexEntry {
+	id, err := strconv.Atoi(d.Info.Name())
+	if err != nil {
+		return nil
+	}
+	if entry, have := known[id]; have && !changedSince(d.Path, since) {
+		return entry
+	}
+	_, i := _fs.LatestChange(d.Path)
+	if i == nil {
+		return nil
+	}
+	title, _ := kegml.ReadTitle(d.Path)
+	return &DexEntry{U: i.ModTime().UTC(), T: title, N: id}
+}
+
+// changedSince returns true if the directory at path or anything
+// directly within it was modified after the time passed.
+func changedSince(path string, since time.Time) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.ModTime().After(since) {
+		return true
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return true
+	}
+	for _, e := range entries {
+		i, err := e.Info()
+		if err != nil || i.ModTime().After(since) {
+			return true
+		}
+	}
+	return false
+}
+
 // MakeDex calls ScanDex and writes (or overwrites) the output to the
-// reserved dex node file within the kegdir passed. File-level
-// locking is attempted using the go-internal/lockedfile (used by Go
-// itself). Both a friendly markdown file reverse sorted by time of last
-// update (changes.md) and a tab-delimited file sorted numerically by
-// node ID (nodes.tsv) are created. Any empty content node directory is
-// automatically removed. Empty is defined to be one that only
-// contains 0-length files, recursively.
+// reserved dex node file within the kegdir passed. The keg-wide lock
+// is held throughout (see Lock). Both a friendly markdown file reverse
+// sorted by time of last update (changes.md) and a tab-delimited file
+// sorted numerically by node ID (nodes.tsv) are created (along with
+// the updated field of the keg file) as a single unit (see WriteDex).
+// Any empty content node directory is automatically removed. Empty is
+// defined to be one that only contains 0-length files, recursively.
 func MakeDex(kegdir string) error {
-	_dex, err := ScanDex(kegdir)
+	unlock, err := Lock(kegdir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return makeDex(kegdir, nil, time.Time{})
+}
+
+// RefreshDex is the same as MakeDex but only rescans node directories
+// that have changed since the dex was last written (see ScanDexSince)
+// keeping the existing entries for all the others. This is usually
+// much faster for large kegs. If there is no dex yet, or it cannot be
+// read, MakeDex is called instead.
+func RefreshDex(kegdir string) error {
+	unlock, err := Lock(kegdir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	info, err := os.Stat(filepath.Join(kegdir, `dex`, `changes.md`))
+	if err != nil {
+		return makeDex(kegdir, nil, time.Time{})
+	}
+	prev, err := ReadDex(kegdir)
+	if err != nil {
+		return makeDex(kegdir, nil, time.Time{})
+	}
+	return makeDex(kegdir, *prev, info.ModTime())
+}
+
+// makeDex does the work of MakeDex and RefreshDex (without the lock).
+// Only rescanned nodes are checked for being empty.
+func makeDex(kegdir string, prev Dex, since time.Time) error {
+	_dex, err := ScanDexSince(kegdir, prev, since)
 	if err != nil {
 		return err
 	}
 
 	// remove any empties that might have crept in
+	known := dexByID(prev)
 	dex := Dex{}
+	var scan map[int]bool
+	if prev != nil {
+		scan = map[int]bool{}
+	}
 	for _, entry := range *_dex {
+		if known[entry.N] == entry {
+			dex = append(dex, entry)
+			continue
+		}
 		d := filepath.Join(kegdir, entry.ID())
 		if dir.IsEmpty(d) {
 			log.Println("❌", d)
@@ -122,21 +315,54 @@ func MakeDex(kegdir string) error {
 			}
 			continue
 		}
+		if scan != nil {
+			scan[entry.N] = true
+		}
 		dex = append(dex, entry)
 	}
 
 	// markdown is first since reverse chrono of updates is default
-	mdpath := filepath.Join(kegdir, `dex`, `changes.md`)
-	if err := file.Overwrite(mdpath, dex.MD()); err != nil {
-		return err
+	return writeDex(kegdir, dex, scan)
+}
+
+// ReadKegInfo reads and parses the keg file within kegpath. Since the
+// keg file is a simplified YAML file that is not always strictly valid
+// YAML (summaries often contain colons, for example) each top-level
+// section is parsed on its own and any that cannot be parsed are
+// quietly skipped.
+func ReadKegInfo(kegpath string) (*KegInfo, error) {
+	buf, err := os.ReadFile(filepath.Join(kegpath, `keg`))
+	if err != nil {
+		return nil, err
 	}
+	return ParseKegInfo(string(buf)), nil
+}
 
-	tsvpath := filepath.Join(kegdir, `dex`, `nodes.tsv`)
-	if err := file.Overwrite(tsvpath, dex.ByID().TSV()); err != nil {
-		return err
+// ParseKegInfo parses the text of a keg file (see ReadKegInfo).
+func ParseKegInfo(text string) *KegInfo {
+	info := new(KegInfo)
+	for _, section := range kegSections(text) {
+		yaml.Unmarshal([]byte(section), info)
 	}
+	return info
+}
 
-	return UpdateUpdated(kegdir)
+// kegSections splits the keg file text into its top-level sections,
+// each beginning with an unindented line.
+func kegSections(text string) []string {
+	var sections []string
+	var cur string
+	for _, line := range strings.Split(text, "\n") {
+		if len(line) > 0 && !strings.ContainsRune(" \t-#", rune(line[0])) && cur != "" {
+			sections = append(sections, cur)
+			cur = ""
+		}
+		cur += line + "\n"
+	}
+	if strings.TrimSpace(cur) != "" {
+		sections = append(sections, cur)
+	}
+	return sections
 }
 
 // UpdateUpdated sets the updated YAML field in the keg info file.
@@ -144,10 +370,12 @@ func UpdateUpdated(kegpath string) error {
 	kegfile := filepath.Join(kegpath, `keg`)
 	updated := UpdatedString(kegpath)
 	return file.ReplaceAllString(
-		kegfile, `(^|\n)updated:.*(\n|$)`, `${1}updated: `+updated+`${2}`,
+		kegfile, updatedFieldExp.String(), `${1}updated: `+updated+`${2}`,
 	)
 }
 
+var updatedFieldExp = regexp.MustCompile(`(^|\n)updated:.*(\n|$)`)
+
 // Updated parses the most recent change time in the dex/node.md file
 // (the first line) and returns the time stamp it contains as
 // a time.Time. If a time stamp could not be determined returns time.
@@ -165,16 +393,11 @@ func Updated(kegpath string) (*time.Time, error) {
 }
 
 // LastChanged parses and returns a DexEntry of the most recently
-// updated node from first line of the dex/changes.md file. If cannot
+// updated node from the first entry of the dex/changes.md file. If cannot
 // determine returns nil.
 func LastChanged(kegpath string) *DexEntry {
-	kegfile := filepath.Join(kegpath, `dex`, `changes.md`)
-	lines, err := file.Head(kegfile, 1)
-	if err != nil || len(lines) == 0 {
-		return nil
-	}
-	dex, err := ParseDex(lines[0])
-	if err != nil {
+	dex, err := ReadChanges(kegpath, 1)
+	if err != nil || len(*dex) == 0 {
 		return nil
 	}
 	return (*dex)[0]
@@ -218,50 +441,32 @@ func UpdatedString(kegpath string) string {
 	return (*u).Format(IsoDateFmt)
 }
 
-// Publish publishes the keg at kegpath location to its distribution
-// targets listed in the keg file under "publish." Currently, this only
-// involves looking for a .git directory and if found doing a git
-// pull/add/commit/push. Git commit messages are always based on the
-// latest node title without any verb.
-func Publish(kegpath string) error {
-	gitd, err := fs.HereOrAbove(`.git`)
-	if err != nil {
-		return nil
-	}
-	origd, err := os.Getwd()
+// MakeNode examines the keg at kegpath for highest integer identifier
+// and provides a new one returning a *DexEntry for it. The keg-wide
+// lock is held (see Lock) and the new node directory is created
+// exclusively so that concurrent callers never get the same one. It is
+// an error if kegpath has no keg file (is not a keg).
+func MakeNode(kegpath string) (*DexEntry, error) {
+	unlock, err := Lock(kegpath)
 	if err != nil {
-		return err
-	}
-	defer os.Chdir(origd)
-	os.Chdir(filepath.Dir(gitd))
-	if err := Z.Exec(`git`, `-C`, kegpath, `pull`); err != nil {
-		if _, is := err.(*exec.ExitError); is {
-			return fmt.Errorf(_NoRemoteRepo, term.Red, term.X)
-		}
-	}
-	if err := Z.Exec(`git`, `-C`, kegpath, `add`, `-A`, `.`); err != nil {
-		return err
-	}
-	msg := "Publish changes"
-	if n := Last(kegpath); n != nil {
-		msg = n.T
-	}
-	if err := Z.Exec(`git`, `-C`, kegpath, `commit`, `-m`, msg); err != nil {
-		return err
+		return nil, err
 	}
-	return Z.Exec(`git`, `-C`, kegpath, `push`)
+	defer unlock()
+	return makeNode(kegpath)
 }
 
-// MakeNode examines the keg at kegpath for highest integer identifier
-// and provides a new one returning a *DexEntry for it.
-func MakeNode(kegpath string) (*DexEntry, error) {
+// makeNode does the work of MakeNode (without the lock).
+func makeNode(kegpath string) (*DexEntry, error) {
+	if !file.Exists(filepath.Join(kegpath, `keg`)) {
+		return nil, fmt.Errorf(_NotKegDir, kegpath)
+	}
 	_, _, high := NodePaths(kegpath)
 	if high < 0 {
 		high = 0
 	}
 	high++
 	path := filepath.Join(kegpath, strconv.Itoa(high))
-	if err := dir.Create(path); err != nil {
+	if err := os.Mkdir(path, iofs.FileMode(dir.DefaultPerms)); err != nil {
 		return nil, err
 	}
 	readme := filepath.Join(kegpath, `dex`, `README.md`)
@@ -289,9 +494,18 @@ func Edit(kegpath string, id int) error {
 // add the new entry without any further validation and call WriteDex
 // create the dex files and update keg file.
 func DexUpdate(kegpath string, entry *DexEntry) error {
+	unlock, err := Lock(kegpath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return updateDex(kegpath, entry)
+}
 
+// updateDex does the work of DexUpdate (without the lock).
+func updateDex(kegpath string, entry *DexEntry) error {
 	if !HaveDex(kegpath) {
-		if err := MakeDex(kegpath); err != nil {
+		if err := makeDex(kegpath, nil, time.Time{}); err != nil {
 			return err
 		}
 	}
@@ -313,7 +527,7 @@ func DexUpdate(kegpath string, entry *DexEntry) error {
 		found.T = entry.T
 	}
 
-	return WriteDex(kegpath, dex)
+	return writeDex(kegpath, dex.ByChanges(), map[int]bool{entry.N: true})
 }
 
 // HaveDex returns true if keg at kegpath has a dex/changes.md file.
@@ -321,23 +535,92 @@ func HaveDex(kegpath string) bool {
 	return file.Exists(filepath.Join(kegpath, `dex`, `changes.md`))
 }
 
-// WriteDex writes the dex/changes.md and dex/nodes.tsv files to the keg
-// at kegpath and calls UpdateUpdated to keep keg info file in sync.
+// WriteDex writes the dex/changes.md, dex/nodes.tsv, and dex/links (see
+// LinksMap) files to the keg at kegpath and updates the updated field of
+// the keg info file to keep it in sync. If the keg file has a feed
+// section the dex/feed.xml Atom
+// feed is also written (see Dex.Atom) and if it has a journal section
+// (or there is a dex/journal.md file) the dex/journal.md index of
+// journal nodes (see Dex.Journal). All are staged to temporary
+// files and renamed into place together so that a failure part way
+// through never leaves them inconsistent with one another.
 func WriteDex(kegpath string, dex *Dex) error {
-	changes := filepath.Join(kegpath, `dex`, `changes.md`)
-	nodes := filepath.Join(kegpath, `dex`, `nodes.tsv`)
-	if err := file.Overwrite(changes, dex.ByChanges().MD()); err != nil {
+	unlock, err := Lock(kegpath)
+	if err != nil {
 		return err
 	}
-	if err := file.Overwrite(nodes, dex.ByID().TSV()); err != nil {
+	defer unlock()
+	return writeDex(kegpath, dex.ByChanges(), nil)
+}
+
+// writeDex does the work of WriteDex (and MakeDex) for a dex already
+// sorted by changes. Only the links of the nodes in scan (all if nil)
+// are read again (see indexLinks).
+func writeDex(kegpath string, dex Dex, scan map[int]bool) error {
+	kegfile := filepath.Join(kegpath, `keg`)
+	buf, err := os.ReadFile(kegfile)
+	if err != nil {
 		return err
 	}
-	return UpdateUpdated(kegpath)
+	var updated string
+	if len(dex) > 0 {
+		updated = dex[0].U.Format(IsoDateFmt)
+	}
+	info := updatedFieldExp.ReplaceAllString(
+		string(buf), `${1}updated: `+updated+`${2}`,
+	)
+	head, foot := readChangesText(kegpath)
+	files := map[string]string{
+		filepath.Join(kegpath, `dex`, `changes.md`): head + dex.MD() + foot,
+		kegfile: info,
+	}
+	files[filepath.Join(kegpath, `dex`, `links`)] = indexLinks(kegpath, dex, scan).String()
+	conf := ParseKegInfo(info)
+	if conf.Feed != nil {
+		feed, err := dex.Atom(conf)
+		if err != nil {
+			return err
+		}
+		files[filepath.Join(kegpath, `dex`, `feed.xml`)] = feed
+	}
+	journal := filepath.Join(kegpath, `dex`, `journal.md`)
+	if conf.Journal != nil || file.Exists(journal) {
+		files[journal] = dex.Journal(conf.Journal.TitleFmt()).AsIncludes()
+	}
+	// last since ByID sorts in place
+	files[filepath.Join(kegpath, `dex`, `nodes.tsv`)] = dex.ByID().TSV()
+	return writeAll(files)
 }
 
 //go:embed testdata/samplekeg/1/README.md
 var SampleNodeReadme string
 
+// NodeText returns the text for a node README.md file with the title
+// line (# TITLE) set to title (if not empty) so that nodes can be
+// created without an editor. The first line of text is replaced if it
+// is already a title and otherwise the title is added before the text
+// followed by a blank line. A line return is added to the end if
+// missing. An error is returned if the result would have no title.
+func NodeText(title, text string) (string, error) {
+	title = strings.TrimSpace(title)
+	hastitle := strings.HasPrefix(text, `# `)
+	switch {
+	case title != "" && hastitle:
+		_, rest, _ := strings.Cut(text, "\n")
+		text = `# ` + title + "\n" + rest
+	case title != "" && strings.TrimSpace(text) == "":
+		text = `# ` + title + "\n"
+	case title != "":
+		text = `# ` + title + "\n\n" + text
+	case !hastitle:
+		return "", fmt.Errorf(_NoNodeTitle)
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text, nil
+}
+
 // WriteSample writes the embedded SampleNodeReadme to the entry
 // indicated in the keg specified by kegpath.
 func WriteSample(kegpath string, entry *DexEntry) error {
@@ -352,55 +635,88 @@ func WriteSample(kegpath string, entry *DexEntry) error {
 // assumed to be a node directory. If not, it is assumed to contain node
 // directories with integer identifiers. Currently, there is no
 // resolution of any links contained within any node README.md file.
-func Import(kegpath string, targets ...string) error {
+// A Dex containing an entry for every node imported is returned (even
+// when there is an error).
+func Import(kegpath string, targets ...string) (Dex, error) {
+	unlock, err := Lock(kegpath)
+	if err != nil {
+		return Dex{}, err
+	}
+	defer unlock()
+
+	dex := Dex{}
 	if !fs.IsDir(kegpath) {
-		return fmt.Errorf(_NotDirNotExist, kegpath)
+		return dex, fmt.Errorf(_NotDirNotExist, kegpath)
 	}
 	for _, target := range targets {
 		if fs.NameIsInt(target) {
-			if err := ImportNode(kegpath, target); err != nil {
-				return err
+			entry, err := importNode(kegpath, target)
+			if err != nil {
+				return dex, err
 			}
+			dex.Add(entry)
 			continue
 		}
 		dirs, _, _ := fs.IntDirs(target)
 		for _, dir := range dirs {
-			if err := ImportNode(kegpath, dir.Path); err != nil {
-				return err
+			entry, err := importNode(kegpath, dir.Path)
+			if err != nil {
+				return dex, err
 			}
+			dex.Add(entry)
 		}
 	}
-	return nil
+	return dex, nil
 }
 
 // ImportNode imports a single specific directory into the kegpath by
 // getting the next integer identifier and moving the target into the
 // kegpath with an os.Rename (which has limitations based on the host
-// operating system's handling of cross-file system boundaries).
-func ImportNode(kegpath, target string) error {
+// operating system's handling of cross-file system boundaries). The
+// DexEntry for the new node is returned.
+func ImportNode(kegpath, target string) (*DexEntry, error) {
+	unlock, err := Lock(kegpath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return importNode(kegpath, target)
+}
+
+// importNode does the work of ImportNode (without the lock).
+func importNode(kegpath, target string) (*DexEntry, error) {
 	var err error
 
 	next := Next(kegpath)
 	if next == nil {
-		return fmt.Errorf(_CantGetNextNode, target)
+		return nil, fmt.Errorf(_CantGetNextNode, target)
 	}
 
 	next.T, err = kegml.ReadTitle(filepath.Join(target, `README.md`))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := os.Rename(target, filepath.Join(kegpath, next.ID())); err != nil {
-		return err
+		return nil, err
 	}
 
-	return DexUpdate(kegpath, next)
+	return next, updateDex(kegpath, next)
 }
 
 // DexRemove removes an entry without changing the current sort order of
 // dex/changes.md and calls WriteDex without a ScanDex.
 func DexRemove(kegpath string, entry *DexEntry) error {
+	unlock, err := Lock(kegpath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return removeDex(kegpath, entry)
+}
 
+// removeDex does the work of DexRemove (without the lock).
+func removeDex(kegpath string, entry *DexEntry) error {
 	dex, err := ReadDex(kegpath)
 	if err != nil {
 		return err
@@ -408,7 +724,7 @@ func DexRemove(kegpath string, entry *DexEntry) error {
 
 	dex.Delete(entry)
 
-	return WriteDex(kegpath, dex)
+	return writeDex(kegpath, dex.ByChanges(), map[int]bool{})
 }
 
 // ReadTags reads an existing dex/tags files within the target keg
@@ -453,13 +769,18 @@ func GrepTags(kegdir, tags string) (string, error) {
 }
 
 // Tag will add the id specified to the dex/tags file, one entry for
-// each line containing one of the comma-separated tags. The
-// rwxrob/fs/file.Overwrite function is used preventing concurrent
-// writes to the file, but it is possible that the file could change
-// by another process before the Overwrite takes place. Therefore,
-// other measures to preserve transactional integrity should be taken
-// where needed. If the dex/tags file does not exist will create it.
+// each line containing one of the comma-separated tags. The file is
+// written with TagsMap.Write which stages it to a temporary file and
+// renames it into place so that it is never left partially written.
+// Since another process could still change the file between reading
+// and writing it, the keg-wide lock is also held (see Lock). If the
+// dex/tags file does not exist will create it.
 func Tag(kegdir, id, tags string) error {
+	unlock, err := Lock(kegdir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	tagsfile := filepath.Join(kegdir, `dex`, `tags`)
 	if err := file.Touch(tagsfile); err != nil {
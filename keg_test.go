@@ -2,7 +2,9 @@ package keg_test
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/rwxrob/keg"
 )
@@ -143,3 +145,159 @@ func ExampleGrepTags_multiple() {
 	// foo 2 6 3
 	// bar 8
 }
+
+func ExampleScanDexSince() {
+	dir, _ := os.MkdirTemp("", "kegscan")
+	defer os.RemoveAll(dir)
+	for _, n := range []string{`1`, `2`} {
+		os.Mkdir(filepath.Join(dir, n), 0700)
+		os.WriteFile(filepath.Join(dir, n, `README.md`), []byte("# Node "+n+"\n"), 0644)
+	}
+	prev := keg.Dex{
+		&keg.DexEntry{N: 1, T: `Old 1`},
+		&keg.DexEntry{N: 3, T: `Gone`},
+	}
+
+	// nothing has changed since an hour from now
+	dex, _ := keg.ScanDexSince(dir, prev, time.Now().Add(time.Hour))
+	for _, e := range dex.ByID() {
+		fmt.Println(e.N, e.T)
+	}
+
+	// everything has changed since an hour ago
+	dex, _ = keg.ScanDexSince(dir, prev, time.Now().Add(-time.Hour))
+	for _, e := range dex.ByID() {
+		fmt.Println(e.N, e.T)
+	}
+
+	// Output:
+	// 1 Old 1
+	// 2 Node 2
+	// 1 Node 1
+	// 2 Node 2
+}
+
+func ExampleParseChanges() {
+	text := "# Latest Changes\n\n" +
+		"* 2022-12-02 07:06:20Z [Second](../2)\n" +
+		"* 2022-11-30 04:35:46Z [First](../1)\n" +
+		"\nSee also [nodes](nodes.tsv).\n"
+	head, dex, foot, err := keg.ParseChanges(text)
+	fmt.Printf("%q\n", head)
+	fmt.Print(dex.TSV())
+	fmt.Printf("%q %v\n", foot, err)
+
+	_, _, _, err = keg.ParseChanges("* 2022-12-02 07:06:20Z [Second](../2)\n* oops\n")
+	fmt.Println(err)
+
+	_, _, _, err = keg.ParseChanges("<<<<<<< HEAD\n* 2022-12-02 07:06:20Z [Second](../2)\n" +
+		"=======\n* 2022-12-01 07:06:20Z [Third](../3)\n>>>>>>> 1a2b3c4\n")
+	fmt.Println(err)
+	_, _, _, err = keg.ParseChanges("* 2022-12-02 07:06:20Z [Second](../2)\n\n" +
+		"<<<<<<< HEAD\nOurs\n=======\nTheirs\n>>>>>>> 1a2b3c4\n")
+	fmt.Println(err)
+
+	// Output:
+	// "# Latest Changes\n\n"
+	// 2	2022-12-02 07:06:20Z	Second
+	// 1	2022-11-30 04:35:46Z	First
+	// "\nSee also [nodes](nodes.tsv).\n" <nil>
+	// bad entry in changes.md at line 2, column 3 (expecting IsoDate)
+	// conflict marker in changes.md at line 1
+	// conflict marker in changes.md at line 3
+}
+
+func ExampleReadChanges() {
+	dex, err := keg.ReadChanges(`testdata/samplekeg`, 2)
+	fmt.Print(dex.TSV())
+	fmt.Println(err)
+	// Output:
+	// 1	2022-11-26 19:33:24Z	Sample content node
+	// 0	2022-11-22 18:05:51Z	Sorry, planned but not yet available
+	// <nil>
+}
+
+func ExampleReadChanges_tsv() {
+	dir, _ := os.MkdirTemp("", "kegchanges")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, `dex`), 0755)
+	os.WriteFile(filepath.Join(dir, `dex`, `nodes.tsv`), []byte(
+		"1\t2022-11-30 04:35:46Z\tFirst\n"+
+			"2\t2022-12-02 07:06:20Z\tSecond\n"+
+			"3\t2022-11-26 19:33:24Z\tThird\n"), 0644)
+	dex, err := keg.ReadChanges(dir, 2)
+	fmt.Print(dex.TSV())
+	fmt.Println(err)
+	// Output:
+	// 2	2022-12-02 07:06:20Z	Second
+	// 1	2022-11-30 04:35:46Z	First
+	// <nil>
+}
+
+func ExampleChangesScanner() {
+	c := keg.NewChangesScanner("# Changes\n\n" +
+		"* 2022-12-02 07:06:20Z [Use [brackets] (and parens)](../2)\n" +
+		"* 2022-11-30 04:35:46Z [Link to [it](../1) here](../3)\n" +
+		"* 2022-11-30 04:35:46Z [Oops](../x)\n")
+	for c.Scan() {
+		fmt.Println(c.Entry().N, c.Entry().T)
+	}
+	fmt.Println(c.Err())
+	// Output:
+	// 2 Use [brackets] (and parens)
+	// 3 Link to [it](../1) here
+	// bad entry in changes.md at line 5, column 34 (expecting NodeID)
+}
+
+func ExampleParseDexTSV() {
+	dex, err := keg.ParseDexTSV("N\tU\tT\n1\t2022-11-30 04:35:46Z\tFirst\n")
+	fmt.Print(dex.TSV())
+	fmt.Println(err)
+	// Output:
+	// 1	2022-11-30 04:35:46Z	First
+	// <nil>
+}
+
+func ExampleWriteDex_preserved() {
+	dir, _ := os.MkdirTemp("", "kegwrite")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, `keg`), []byte("updated:\n"), 0644)
+	os.Mkdir(filepath.Join(dir, `dex`), 0700)
+	changes := filepath.Join(dir, `dex`, `changes.md`)
+	os.WriteFile(changes, []byte("# Latest Changes\n\n"+
+		"* 2022-11-30 04:35:46Z [First](../1)\n\nThe end.\n"), 0644)
+
+	dex, _ := keg.ReadDex(dir)
+	dex.Add(&keg.DexEntry{N: 2, T: `Second`,
+		U: time.Date(2022, 12, 2, 7, 6, 20, 0, time.UTC)})
+	keg.WriteDex(dir, dex)
+	buf, _ := os.ReadFile(changes)
+	fmt.Print(string(buf))
+
+	// Output:
+	// # Latest Changes
+	//
+	// * 2022-12-02 07:06:20Z [Second](../2)
+	// * 2022-11-30 04:35:46Z [First](../1)
+	//
+	// The end.
+}
+
+func ExampleNodeText() {
+	for _, c := range [][2]string{
+		{`Just a title`, ``},
+		{`Title`, "Some body"},
+		{`New title`, "# Old title\n\nSome body\n"},
+		{``, "# Own title\n\nSome body"},
+		{``, "Some body"},
+	} {
+		text, err := keg.NodeText(c[0], c[1])
+		fmt.Printf("%q %v\n", text, err)
+	}
+	// Output:
+	// "# Just a title\n" <nil>
+	// "# Title\n\nSome body\n" <nil>
+	// "# New title\n\nSome body\n" <nil>
+	// "# Own title\n\nSome body\n" <nil>
+	// "" node has no title (begin with "# TITLE" or use --title)
+}
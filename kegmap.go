@@ -0,0 +1,138 @@
+package keg
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rwxrob/fs"
+	"gopkg.in/yaml.v3"
+)
+
+// MapEntry is a single named keg from the map of local kegs kept in
+// the configuration (see ReadMap).
+type MapEntry struct {
+	Name string
+	Path string // as configured (may begin with ~)
+	Dir  string // fully qualified keg directory (docs if it has one)
+}
+
+// ReadMap parses the YAML map of keg names to directories (as found in
+// the map configuration entry) and returns the entries sorted by name.
+// A tilde at the beginning of each path is expanded and the docs
+// directory is used if the path contains a docs/keg file. An empty
+// string or null returns an empty list.
+func ReadMap(data string) ([]MapEntry, error) {
+	m := map[string]string{}
+	if err := yaml.Unmarshal([]byte(data), &m); err != nil {
+		return nil, err
+	}
+	var list []MapEntry
+	for name, path := range m {
+		dir := fs.Tilde2Home(path)
+		docs := filepath.Join(dir, `docs`)
+		if fs.Exists(filepath.Join(docs, `keg`)) {
+			dir = docs
+		}
+		list = append(list, MapEntry{Name: name, Path: path, Dir: dir})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// yamlSet returns the YAML document data (which may be empty) with the
+// string value at the path of keys set, creating any mappings needed
+// along the way. Everything else (including order and comments) is
+// preserved.
+func yamlSet(data string, keys []string, val string) (*yaml.Node, error) {
+	doc, err := yamlDoc(data)
+	if err != nil {
+		return nil, err
+	}
+	node := doc.Content[0]
+	for _, key := range keys[:len(keys)-1] {
+		next := yamlLookup(node, key)
+		if next == nil || next.Kind != yaml.MappingNode {
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: `!!map`}
+			yamlPut(node, key, next)
+		}
+		node = next
+	}
+	yamlPut(node, keys[len(keys)-1],
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: `!!str`, Value: val})
+	return doc, nil
+}
+
+// yamlDel returns the YAML document data with the entry at the path of
+// keys removed and whether it was found.
+func yamlDel(data string, keys []string) (*yaml.Node, bool, error) {
+	doc, err := yamlDoc(data)
+	if err != nil {
+		return nil, false, err
+	}
+	node := doc.Content[0]
+	for _, key := range keys[:len(keys)-1] {
+		node = yamlLookup(node, key)
+		if node == nil || node.Kind != yaml.MappingNode {
+			return doc, false, nil
+		}
+	}
+	last := keys[len(keys)-1]
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == last {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return doc, true, nil
+		}
+	}
+	return doc, false, nil
+}
+
+// yamlDoc parses the data into a document node that always contains
+// a mapping.
+func yamlDoc(data string) (*yaml.Node, error) {
+	doc := new(yaml.Node)
+	if err := yaml.Unmarshal([]byte(data), doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode {
+		doc = &yaml.Node{Kind: yaml.DocumentNode}
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: `!!map`}}
+	}
+	return doc, nil
+}
+
+// yamlLookup returns the value for key within the mapping node or nil.
+func yamlLookup(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// yamlPut sets (or adds) the value for key within the mapping node.
+func yamlPut(node *yaml.Node, key string, val *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = val
+			return
+		}
+	}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: `!!str`, Value: key}, val)
+}
+
+// confKeys returns the keys for the configuration entry at the given
+// dotted path (as returned by Z.Cmd.Path) with more keys appended.
+func confKeys(path string, more ...string) []string {
+	var keys []string
+	for _, k := range strings.Split(path, `.`) {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return append(keys, more...)
+}
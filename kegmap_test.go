@@ -0,0 +1,21 @@
+package keg_test
+
+import (
+	"fmt"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleReadMap() {
+	list, _ := keg.ReadMap("zet: testdata/samplekeg\nold: /nope\n")
+	for _, e := range list {
+		fmt.Println(e.Name, e.Dir)
+	}
+	list, _ = keg.ReadMap(`null`)
+	fmt.Println(len(list))
+
+	// Output:
+	// old /nope
+	// zet testdata/samplekeg
+	// 0
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/rwxrob/pegn"
 	"github.com/rwxrob/pegn/ast"
+	"github.com/rwxrob/pegn/curs"
 	"github.com/rwxrob/pegn/scanner"
 )
 
@@ -17,8 +18,18 @@ var PEGN string
 const (
 	Untyped int = iota
 	Title
+	DexEntry
+	IsoDate
+	NodeTitle
+	NodeID
 )
 
+// RuleNames are the names (from the grammar) of the rule types above
+// for use in error messages.
+var RuleNames = []string{
+	`Untyped`, `Title`, `DexEntry`, `IsoDate`, `NodeTitle`, `NodeID`,
+}
+
 // ------------------------------- Title ------------------------------
 
 func ScanTitle(s pegn.Scanner, buf *[]rune) bool {
@@ -58,27 +69,166 @@ func ParseTitle(s pegn.Scanner) *ast.Node {
 	return &ast.Node{T: Title, V: string(buf)}
 }
 
+// ----------------------------- DexEntry -----------------------------
+
+// scanRune scans the next rune and returns true if it is r.
+func scanRune(s pegn.Scanner, r rune) bool {
+	return s.Scan() && s.Rune() == r
+}
+
+// ScanIsoDate scans a date and time in UTC in the form used by the dex
+// (2006-01-02 15:04:05Z).
+func ScanIsoDate(s pegn.Scanner, buf *[]rune) bool {
+	m := s.Mark()
+	for _, want := range `0000-00-00 00:00:00Z` {
+		n := s.Mark()
+		if !s.Scan() {
+			return s.Revert(m, IsoDate)
+		}
+		r := s.Rune()
+		if want == '0' && (r < '0' || r > '9') || want != '0' && r != want {
+			s.Goto(n)
+			return s.Revert(m, IsoDate)
+		}
+		if buf != nil {
+			*buf = append(*buf, r)
+		}
+	}
+	return true
+}
+
+// ScanNodeID scans one or more digits.
+func ScanNodeID(s pegn.Scanner, buf *[]rune) bool {
+	m := s.Mark()
+	var count int
+	for {
+		n := s.Mark()
+		if !s.Scan() || s.Rune() < '0' || s.Rune() > '9' {
+			s.Goto(n)
+			break
+		}
+		if buf != nil {
+			*buf = append(*buf, s.Rune())
+		}
+		count++
+	}
+	if count == 0 {
+		return s.Revert(m, NodeID)
+	}
+	return true
+}
+
+// ParseDexEntry parses a single entry of the dex/changes.md file (not
+// including the line ending) into a DexEntry node with IsoDate,
+// NodeTitle, and NodeID nodes under it. The title is everything up to
+// the last "](../" on the line so that titles may contain "]" and ")".
+// If unable to parse, nil is returned, the scanner is returned to where
+// it was, and an error (pegn.Error) is pushed with the rule that was
+// expected and the position at which it was expected.
+func ParseDexEntry(s pegn.Scanner) *ast.Node {
+	m := s.Mark()
+	fail := func(rule int) *ast.Node {
+		s.Expected(rule)
+		s.Goto(m)
+		return nil
+	}
+
+	if !scanRune(s, '*') || !scanRune(s, ' ') {
+		return fail(DexEntry)
+	}
+	date := make([]rune, 0, 20)
+	if !ScanIsoDate(s, &date) {
+		s.Goto(m)
+		return nil
+	}
+	for _, r := range ` [` {
+		n := s.Mark()
+		if !scanRune(s, r) {
+			s.Goto(n)
+			return fail(DexEntry)
+		}
+	}
+
+	// remember where every "](../" ends on the rest of the line
+	tbeg := s.Mark()
+	var line []rune
+	var ends []curs.R
+	for {
+		n := s.Mark()
+		if !s.Scan() || s.Rune() == '\n' || s.Rune() == '\r' {
+			s.Goto(n)
+			break
+		}
+		if s.Rune() < ' ' {
+			return fail(NodeTitle)
+		}
+		line = append(line, s.Rune())
+		if l := len(line); l >= 5 && string(line[l-5:]) == `](../` {
+			ends = append(ends, s.Mark())
+		}
+	}
+	eol := s.Mark()
+	if len(ends) == 0 {
+		return fail(DexEntry)
+	}
+
+	// the title is up to the last one followed by the node ID
+	s.Goto(ends[len(ends)-1])
+	title := s.CopyEE(tbeg)
+	title = title[:len(title)-len(`](../`)]
+	if len(title) == 0 {
+		s.Goto(tbeg)
+		return fail(NodeTitle)
+	}
+	id := make([]rune, 0, 8)
+	if !ScanNodeID(s, &id) {
+		return fail(NodeID)
+	}
+	n := s.Mark()
+	if !scanRune(s, ')') {
+		s.Goto(n)
+		return fail(DexEntry)
+	}
+	if s.Mark().E != eol.E {
+		return fail(DexEntry)
+	}
+
+	node := &ast.Node{T: DexEntry}
+	node.Add(IsoDate, string(date))
+	node.Add(NodeTitle, title)
+	node.Add(NodeID, string(id))
+	return node
+}
+
 var Scanner pegn.Scanner
 
-func init() {
-	Scanner = scanner.New()
-	Scanner.SetErrFmtFunc(
+func init() { Scanner = NewScanner() }
+
+// NewScanner returns a new pegn.Scanner with the error format used by
+// this package.
+func NewScanner() pegn.Scanner {
+	s := scanner.New()
+	s.SetErrFmtFunc(
 		func(e error) string {
 			return fmt.Sprintf("custom %q\n", e)
 		})
+	return s
 }
 
-// ReadTitle reads a KEG node title from KEGML file.
+// ReadTitle reads a KEG node title from KEGML file. A new Scanner is
+// used for every call so that it is safe to call from multiple
+// goroutines at once.
 func ReadTitle(path string) (string, error) {
 	if !strings.HasSuffix(path, `README.md`) {
 		path = filepath.Join(path, `README.md`)
 	}
-	if err := Scanner.Open(path); err != nil {
+	s := NewScanner()
+	if err := s.Open(path); err != nil {
 		return "", err
 	}
-	nd := ParseTitle(Scanner)
+	nd := ParseTitle(s)
 	if nd == nil {
-		return "", Scanner
+		return "", s
 	}
 	return nd.V, nil
 }
@@ -79,3 +79,30 @@ func ExampleTitle_parsed_Long() {
 
 }
 */
+
+func ExampleParseDexEntry() {
+	s := scanner.New("* 2022-12-02 07:06:20Z [Some [odd] (title)](../12)\n")
+	nd := kegml.ParseDexEntry(s)
+	for _, n := range nd.Nodes() {
+		fmt.Printf("%v %q\n", kegml.RuleNames[n.T], n.V)
+	}
+	s.Print()
+
+	// Output:
+	// IsoDate "2022-12-02 07:06:20Z"
+	// NodeTitle "Some [odd] (title)"
+	// NodeID "12"
+	// ')' 49-50 "\n"
+}
+
+func ExampleParseDexEntry_bad() {
+	s := scanner.New("* 2022-12-2 07:06:20Z [Title](../12)")
+	fmt.Println(kegml.ParseDexEntry(s))
+	fmt.Println(s.ErrPop())
+	s.Print()
+
+	// Output:
+	// <nil>
+	// expecting type 3 at '2' 10-11
+	// '\x00' 0-0 "* 2022-12-"
+}
@@ -0,0 +1,224 @@
+package keg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rwxrob/to"
+)
+
+// Link is a link to a node, either in the same keg (../ID) or in
+// another keg on the local system named in the conf map (keg:NAME/ID).
+type Link struct {
+	Keg string // name of keg from map (empty for same keg)
+	N   int    // node ID
+}
+
+// String returns the link in its KEGML form, ../ID or keg:NAME/ID.
+func (l Link) String() string {
+	if l.Keg == "" {
+		return `../` + strconv.Itoa(l.N)
+	}
+	return `keg:` + l.Keg + `/` + strconv.Itoa(l.N)
+}
+
+// CrossLinkExp matches a cross-keg link (keg:NAME/ID) capturing the
+// NAME and ID.
+var CrossLinkExp = regexp.MustCompile(`^keg:([^\s/.()\[\]]+)/(\d+)/?$`)
+
+// LinkExp matches the target of any Markdown link to a node (in the
+// same keg or another) capturing the whole target.
+var LinkExp = regexp.MustCompile(`\]\((\.\./\d+/?|keg:[^\s/.()\[\]]+/\d+/?)\)`)
+
+// ParseLink parses a node link (../ID or keg:NAME/ID) returning false
+// if it is neither.
+func ParseLink(s string) (Link, bool) {
+	if f := CrossLinkExp.FindStringSubmatch(s); f != nil {
+		n, err := strconv.Atoi(f[2])
+		return Link{Keg: f[1], N: n}, err == nil
+	}
+	if !strings.HasPrefix(s, `../`) {
+		return Link{}, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(s[3:], `/`))
+	return Link{N: n}, err == nil
+}
+
+// Links returns every node link within the KEGML (Markdown) text in
+// the order found.
+func Links(text string) []Link {
+	var links []Link
+	for _, m := range LinkExp.FindAllStringSubmatch(text, -1) {
+		if l, ok := ParseLink(m[1]); ok {
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// Dir returns the node directory the link refers to. Links within the
+// same keg are relative to kegpath. Links to other kegs are looked up
+// by name in kegs (see ReadMap).
+func (l Link) Dir(kegpath string, kegs []MapEntry) (string, error) {
+	if l.Keg == "" {
+		return filepath.Join(kegpath, strconv.Itoa(l.N)), nil
+	}
+	for _, k := range kegs {
+		if k.Name == l.Keg {
+			return filepath.Join(k.Dir, strconv.Itoa(l.N)), nil
+		}
+	}
+	return "", fmt.Errorf(_KegNotMapped, l.Keg)
+}
+
+// URL returns the Web URL for the node the link refers to using the
+// linkfmt field of the keg file of its keg (see ReadKegInfo). If there
+// is no linkfmt the path to the node README.md file is returned
+// instead.
+func (l Link) URL(kegpath string, kegs []MapEntry) (string, error) {
+	dir, err := l.Dir(kegpath, kegs)
+	if err != nil {
+		return "", err
+	}
+	info, err := ReadKegInfo(filepath.Dir(dir))
+	if err != nil || !strings.Contains(info.LinkFmt, `{{id}}`) {
+		return filepath.Join(dir, `README.md`), nil
+	}
+	return strings.Replace(info.LinkFmt, `{{id}}`, strconv.Itoa(l.N), 1), nil
+}
+
+// ExpandLinks replaces the targets of all cross-keg links (keg:NAME/ID)
+// within the KEGML text with their URL (see Link.URL) so that they can
+// be followed from rendered output. Links that cannot be resolved and
+// links within the same keg are left as is.
+func ExpandLinks(text, kegpath string, kegs []MapEntry) string {
+	return LinkExp.ReplaceAllStringFunc(text, func(m string) string {
+		l, ok := ParseLink(m[2 : len(m)-1])
+		if !ok || l.Keg == "" {
+			return m
+		}
+		url, err := l.URL(kegpath, kegs)
+		if err != nil {
+			return m
+		}
+		return `](` + url + `)`
+	})
+}
+
+// LinksMap is the index of the node links (see NodeLinks) within the
+// README.md of every node of a keg by node ID kept in the dex/links
+// file (see WriteDex). Each line of the file is the ID of a node
+// followed by its links (../ID or keg:NAME/ID) separated by spaces.
+type LinksMap map[int][]Link
+
+// String renders the LinksMap in the form of the dex/links file with
+// the lines sorted by ID.
+func (m LinksMap) String() string {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var buf strings.Builder
+	for _, id := range ids {
+		buf.WriteString(strconv.Itoa(id))
+		for _, l := range m[id] {
+			buf.WriteString(` ` + l.String())
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// ParseLinks parses any input valid for to.String in the form of the
+// dex/links file into a LinksMap. Blank lines are skipped.
+func ParseLinks(in any) (LinksMap, error) {
+	m := LinksMap{}
+	s := bufio.NewScanner(strings.NewReader(to.String(in)))
+	for line := 1; s.Scan(); line++ {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 {
+			continue
+		}
+		id, err := strconv.Atoi(f[0])
+		if err != nil {
+			return nil, fmt.Errorf(_BadLinksLine, line)
+		}
+		links := []Link{}
+		for _, t := range f[1:] {
+			l, ok := ParseLink(t)
+			if !ok {
+				return nil, fmt.Errorf(_BadLinksLine, line)
+			}
+			links = append(links, l)
+		}
+		m[id] = links
+	}
+	return m, nil
+}
+
+// ReadLinks reads and parses the dex/links file of the keg at kegpath.
+func ReadLinks(kegpath string) (LinksMap, error) {
+	buf, err := os.ReadFile(filepath.Join(kegpath, `dex`, `links`))
+	if err != nil {
+		return nil, err
+	}
+	return ParseLinks(buf)
+}
+
+// To returns the IDs (sorted) of the nodes with a link to the target.
+func (m LinksMap) To(target Link) []int {
+	var ids []int
+	for id, links := range m {
+		for _, l := range links {
+			if l == target {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// NodeLinks returns the node links (see ParseLink) within the KEGML
+// text of a node in the order first found without duplicates. As with
+// FileLinks, links within fenced blocks and code spans are ignored.
+func NodeLinks(text string) []Link {
+	links := []Link{}
+	seen := map[Link]bool{}
+	MapLinkTargets(text, func(target string) string {
+		if l, ok := ParseLink(target); ok && !seen[l] {
+			seen[l] = true
+			links = append(links, l)
+		}
+		return target
+	})
+	return links
+}
+
+// indexLinks returns the LinksMap for every node of the dex of the keg
+// at kegpath. Only the nodes in scan (all if nil) and any missing from
+// the existing dex/links file are read again.
+func indexLinks(kegpath string, dex Dex, scan map[int]bool) LinksMap {
+	var prev LinksMap
+	if scan != nil {
+		prev, _ = ReadLinks(kegpath)
+	}
+	m := LinksMap{}
+	for _, e := range dex {
+		if links, ok := prev[e.N]; ok && !scan[e.N] {
+			m[e.N] = links
+			continue
+		}
+		buf, _ := os.ReadFile(filepath.Join(kegpath, e.ID(), `README.md`))
+		m[e.N] = NodeLinks(string(buf))
+	}
+	return m
+}
@@ -0,0 +1,78 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleLinks() {
+	text := "See [this](../2) and [that](keg:team/42) but not [web](https://x/3).\n"
+	for _, l := range keg.Links(text) {
+		fmt.Printf("%q %v %v\n", l.Keg, l.N, l)
+	}
+	// Output:
+	// "" 2 ../2
+	// "team" 42 keg:team/42
+}
+
+func ExampleExpandLinks() {
+	kegs := []keg.MapEntry{{Name: `sample`, Dir: `testdata/samplekeg`}}
+	text := "[a](keg:sample/3) [b](keg:nope/1) [c](../4)\n"
+	fmt.Print(keg.ExpandLinks(text, `.`, kegs))
+	// Output:
+	// [a](testdata/samplekeg/3/README.md) [b](keg:nope/1) [c](../4)
+}
+
+func ExampleNodeLinks() {
+	text := "# Title\n\nSee [this](../2), [that](keg:team/42), [again](../2/),\n" +
+		"and `[code](../3)`.\n\n```\n[example](../4)\n```\n"
+	fmt.Println(keg.NodeLinks(text))
+	// Output:
+	// [../2 keg:team/42]
+}
+
+func ExampleParseLinks() {
+	links, err := keg.ParseLinks("1 ../2 keg:team/42\n2\n3 ../2\n")
+	fmt.Print(links)
+	fmt.Println(err)
+	fmt.Println(links.To(keg.Link{N: 2}), links.To(keg.Link{Keg: `team`, N: 42}))
+
+	_, err = keg.ParseLinks("1 ../2 https://x\n")
+	fmt.Println(err)
+
+	// Output:
+	// 1 ../2 keg:team/42
+	// 2
+	// 3 ../2
+	// <nil>
+	// [1 3] [1]
+	// bad line in dex/links: 1
+}
+
+func ExampleReadLinks() {
+	dir, _ := os.MkdirTemp("", "keglinks")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, `keg`), []byte("updated:\n"), 0644)
+	node := func(id int, text string) {
+		os.MkdirAll(filepath.Join(dir, fmt.Sprint(id)), 0755)
+		os.WriteFile(filepath.Join(dir, fmt.Sprint(id), `README.md`), []byte(text), 0644)
+		keg.DexUpdate(dir, &keg.DexEntry{N: id})
+	}
+	node(1, "# One\n")
+	node(2, "# Two\n\nSee [one](../1).\n")
+	node(3, "# Three\n\nSee [two](../2) and [team](keg:team/7).\n")
+	node(2, "# Two\n\nSee [three](../3) now.\n")
+	keg.DexRemove(dir, &keg.DexEntry{N: 1})
+
+	links, err := keg.ReadLinks(dir)
+	fmt.Print(links)
+	fmt.Println(err)
+
+	// Output:
+	// 2 ../3
+	// 3 ../2 keg:team/7
+	// <nil>
+}
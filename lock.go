@@ -0,0 +1,127 @@
+package keg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockFile is the name of the keg-wide lock file created within the keg
+// directory by Lock. It only exists while the lock is held.
+var LockFile = `.keg.lock`
+
+// LockTimeout is how long Lock waits for another process to release
+// the keg lock before giving up.
+var LockTimeout = 10 * time.Second
+
+// LockStale is how old a lock file must be before it is assumed to
+// have been abandoned (by a crashed process, for example) and is
+// removed.
+var LockStale = 5 * time.Minute
+
+// LockError is returned by Lock when the keg lock is held by another
+// process for longer than LockTimeout.
+type LockError struct {
+	Path  string    // path to lock file
+	Owner string    // process ID and host name of owner (if known)
+	Since time.Time // when lock was created
+}
+
+// Error fulfills the error interface.
+func (e LockError) Error() string {
+	return fmt.Sprintf(
+		_KegLocked, e.Owner, e.Since.UTC().Format(IsoDateFmt), e.Path,
+	)
+}
+
+// held has a mutex for each keg (by absolute path) so that goroutines
+// within the same process exclude each other before the lock file is
+// even tried.
+var held = struct {
+	sync.Mutex
+	mu map[string]*sync.Mutex
+}{mu: map[string]*sync.Mutex{}}
+
+// Lock acquires the keg-wide lock for the keg at kegpath and returns
+// a function to release it. Everything that changes the dex or
+// allocates node IDs (MakeNode, MakeDex, DexUpdate, DexRemove, WriteDex,
+// Tag, and Import) holds this lock so that concurrent keg commands
+// (or a script and an editor, or goroutines of the same process) do
+// not collide.
+//
+// The lock is a file (see LockFile) created exclusively and removed
+// when released, which works on any file system including shared ones.
+// If another process holds the lock, Lock retries until LockTimeout and
+// then returns a LockError. Lock files older than LockStale are
+// removed. Within the same process a mutex for the keg is held as well
+// (without any timeout). Lock is not reentrant: functions that hold it
+// call unexported versions of the others that do not lock (such as
+// writeDex) rather than the exported ones.
+func Lock(kegpath string) (unlock func(), err error) {
+	abs, err := filepath.Abs(kegpath)
+	if err != nil {
+		return nil, err
+	}
+	held.Lock()
+	mu, has := held.mu[abs]
+	if !has {
+		mu = new(sync.Mutex)
+		held.mu[abs] = mu
+	}
+	held.Unlock()
+
+	mu.Lock()
+	path := filepath.Join(abs, LockFile)
+	unlock = func() {
+		os.Remove(path)
+		mu.Unlock()
+	}
+	defer func() {
+		if err != nil {
+			mu.Unlock()
+		}
+	}()
+
+	host, _ := os.Hostname()
+	owner := strconv.Itoa(os.Getpid()) + ` ` + host
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = f.WriteString(owner + "\n")
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return unlock, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		info, serr := os.Stat(path)
+		if serr != nil {
+			continue // released between attempts
+		}
+		if time.Since(info.ModTime()) > LockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			buf, _ := os.ReadFile(path)
+			return nil, LockError{
+				Path:  path,
+				Owner: strings.TrimSpace(string(buf)),
+				Since: info.ModTime(),
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
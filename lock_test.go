@@ -0,0 +1,62 @@
+package keg_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleLock() {
+	dir, _ := os.MkdirTemp("", "keglock")
+	defer os.RemoveAll(dir)
+	lockfile := filepath.Join(dir, keg.LockFile)
+
+	unlock, err := keg.Lock(dir)
+	fmt.Println(err)
+	got := make(chan bool)
+	go func() {
+		again, _ := keg.Lock(dir) // waits for unlock
+		got <- true
+		again()
+		got <- true
+	}()
+	select {
+	case <-got:
+		fmt.Println(`not excluded`)
+	case <-time.After(100 * time.Millisecond):
+		fmt.Println(`excluded`)
+	}
+	_, err = os.Stat(lockfile)
+	fmt.Println(err == nil)
+	unlock()
+	<-got
+	<-got
+	_, err = os.Stat(lockfile)
+	fmt.Println(err == nil)
+
+	// Output:
+	// <nil>
+	// excluded
+	// true
+	// false
+}
+
+func ExampleLock_held() {
+	dir, _ := os.MkdirTemp("", "keglock")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, keg.LockFile), []byte("1 otherhost\n"), 0644)
+
+	defer func(t time.Duration) { keg.LockTimeout = t }(keg.LockTimeout)
+	keg.LockTimeout = 100 * time.Millisecond
+
+	_, err := keg.Lock(dir)
+	var lerr keg.LockError
+	fmt.Println(errors.As(err, &lerr), lerr.Owner)
+
+	// Output:
+	// true 1 otherhost
+}
@@ -10,11 +10,11 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/rwxrob/choose"
 	"github.com/rwxrob/fs"
-	"github.com/rwxrob/fs/file"
 	"github.com/rwxrob/json"
 	"github.com/rwxrob/keg/kegml"
 	"github.com/rwxrob/term"
@@ -29,6 +29,24 @@ type Local struct {
 	Path string
 }
 
+// KegInfo contains the fields of the keg file (a simplified YAML file
+// at the root of every keg) that are used by the keg command. See
+// ReadKegInfo.
+type KegInfo struct {
+	Updated string       `yaml:"updated"`
+	Kegv    string       `yaml:"kegv"`
+	Title   string       `yaml:"title"`
+	URL     string       `yaml:"url"`
+	Creator string       `yaml:"creator"`
+	State   string       `yaml:"state"`
+	Summary string       `yaml:"summary"`
+	LinkFmt string       `yaml:"linkfmt"`
+	Publish PublishConf  `yaml:"publish"`
+	Feed    *FeedConf    `yaml:"feed"`
+	Journal *JournalConf `yaml:"journal"`
+	Assets  *AssetsConf  `yaml:"assets"`
+}
+
 // DexEntry represents a single line in an index (usually the changes.md
 // or nodes.tsv file). All three fields are always required.
 type DexEntry struct {
@@ -46,7 +64,7 @@ func (e *DexEntry) Update(kegpath string) error {
 	dir := filepath.Join(kegpath, e.ID())
 	_, i := fs.LatestChange(dir)
 	if i != nil {
-		e.U = i.ModTime()
+		e.U = i.ModTime().UTC()
 	}
 	e.T, err = kegml.ReadTitle(filepath.Join(dir, `README.md`))
 	return err
@@ -100,6 +118,13 @@ func (e *DexEntry) AsInclude() string {
 	return fmt.Sprintf("* [%v](../%v)", e.T, e.N)
 }
 
+// AsKegInclude is the same as AsInclude but links to the node within
+// the named keg (keg:NAME/ID) for including from another keg (see
+// Link).
+func (e *DexEntry) AsKegInclude(name string) string {
+	return fmt.Sprintf("* [%v](keg:%v/%v)", e.T, name, e.N)
+}
+
 // Pretty returns a string with pretty colors.
 func (e *DexEntry) Pretty() string {
 	nwidth := len(e.ID())
@@ -128,6 +153,9 @@ type Dex []*DexEntry
 // MarshalJSON produces JSON text that contains one DexEntry per line
 // that has not been HTML escaped (unlike the default).
 func (d *Dex) MarshalJSON() ([]byte, error) {
+	if len(*d) == 0 {
+		return []byte(`[]`), nil
+	}
 	buf := bytes.NewBuffer(make([]byte, 0, 0))
 	buf.WriteString("[")
 	for _, entry := range *d {
@@ -158,31 +186,45 @@ func (e Dex) String() string { return e.TSV() }
 // MD renders the entire Dex as a Markdown list suitable for the
 // standard dex/changes.md file.
 func (e Dex) MD() string {
-	var str string
+	var buf strings.Builder
 	for _, entry := range e {
-		str += entry.MD() + "\n"
+		buf.WriteString(entry.MD())
+		buf.WriteByte('\n')
 	}
-	return str
+	return buf.String()
 }
 
 // AsIncludes renders the entire Dex as a KEGML include list (markdown
 // bulleted list) and cab be useful from within editing sessions to
 // include from the current keg without leaving the terminal editor.
 func (e Dex) AsIncludes() string {
-	var str string
+	var buf strings.Builder
 	for _, entry := range e {
-		str += entry.AsInclude() + "\n"
+		buf.WriteString(entry.AsInclude())
+		buf.WriteByte('\n')
 	}
-	return str
+	return buf.String()
+}
+
+// AsKegIncludes is the same as AsIncludes but links to the nodes within
+// the named keg (see AsKegInclude).
+func (e Dex) AsKegIncludes(name string) string {
+	var buf strings.Builder
+	for _, entry := range e {
+		buf.WriteString(entry.AsKegInclude(name))
+		buf.WriteByte('\n')
+	}
+	return buf.String()
 }
 
 // TSV renders the entire Dex as a loadable tab-separated values file.
 func (e Dex) TSV() string {
-	var str string
+	var buf strings.Builder
 	for _, entry := range e {
-		str += entry.TSV() + "\n"
+		buf.WriteString(entry.TSV())
+		buf.WriteByte('\n')
 	}
-	return str
+	return buf.String()
 }
 
 // Last returns the DexEntry with the highest integer value identifier.
@@ -388,6 +430,128 @@ func (d *Dex) Delete(entry *DexEntry) {
 	(*d) = (*d)[:len(*d)-1]
 }
 
+// ------------------------------ Change ------------------------------
+
+// Operations that can be done to a keg and described by a Change.
+const (
+	OpCreated     = `created`
+	OpEdited      = `edited`
+	OpDeleted     = `deleted`
+	OpImported    = `imported`
+	OpTagged      = `tagged`
+	OpInitialized = `initialized`
+	OpUpdated     = `updated`
+	OpSent        = `sent`
+	OpReceived    = `received`
+	OpSplit       = `split`
+	OpMerged      = `merged`
+)
+
+// DefaultChangeMessage is the text/template used by Change.Message when
+// no other is given. It produces messages like the following:
+//
+//	Edited 3: Some title for 3
+//	Tagged 6 (foo,bar): Some title for 6
+//	Imported 13, 14: Some title; Another title
+const DefaultChangeMessage = `{{.Verb}}{{with .IDs}} {{.}}{{end}}` +
+	`{{with .Note}} ({{.}}){{end}}{{with .Titles}}: {{.}}{{end}}`
+
+// MaxChangeTitles is the most titles included by Change.Titles.
+var MaxChangeTitles = 3
+
+// Change describes an operation done to a keg so that it can be
+// published with a meaningful message (see Publish).
+type Change struct {
+	Op    string      // usually one of the Op* constants
+	Nodes []*DexEntry // nodes affected (if any)
+	Note  string      // optional details (ex: tags)
+}
+
+// Verb returns the Op with the first letter capitalized.
+func (c *Change) Verb() string {
+	if c.Op == "" {
+		return ""
+	}
+	return strings.ToUpper(c.Op[:1]) + c.Op[1:]
+}
+
+// IDs returns the node IDs of the Nodes separated by a comma and space.
+func (c *Change) IDs() string {
+	ids := make([]string, 0, len(c.Nodes))
+	for _, n := range c.Nodes {
+		ids = append(ids, n.ID())
+	}
+	return strings.Join(ids, `, `)
+}
+
+// Titles returns the non-empty titles of the Nodes separated by
+// a semicolon and space. Only MaxChangeTitles are included followed by
+// a count of those omitted.
+func (c *Change) Titles() string {
+	var titles []string
+	var more int
+	for _, n := range c.Nodes {
+		if n.T == "" {
+			continue
+		}
+		if len(titles) >= MaxChangeTitles {
+			more++
+			continue
+		}
+		titles = append(titles, n.T)
+	}
+	str := strings.Join(titles, `; `)
+	if more > 0 {
+		str += fmt.Sprintf(`; and %v more`, more)
+	}
+	return str
+}
+
+// Covers returns true if every one of the node ids passed is in the
+// Nodes of the Change. Always returns false if Change is nil.
+func (c *Change) Covers(ids []string) bool {
+	if c == nil {
+		return false
+	}
+	have := map[string]bool{}
+	for _, n := range c.Nodes {
+		have[n.ID()] = true
+	}
+	for _, id := range ids {
+		if !have[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// Message executes the text/template tmpl (or DefaultChangeMessage if
+// empty) with the Change as data and returns the trimmed result,
+// usually for use as a commit message. The template has access to the
+// Op, Nodes, Note, Verb, IDs, and Titles. If Change is nil or the result
+// is empty, "Publish changes" is returned.
+func (c *Change) Message(tmpl string) (string, error) {
+	if c == nil {
+		return `Publish changes`, nil
+	}
+	if tmpl == "" {
+		tmpl = DefaultChangeMessage
+	}
+	t, err := template.New(`message`).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, c); err != nil {
+		return "", err
+	}
+	msg := strings.TrimSpace(buf.String())
+	if msg == "" {
+		return `Publish changes`, nil
+	}
+	return msg, nil
+}
+
 // ----------------------------- TagsList -----------------------------
 
 type TagsMap map[string][]string
@@ -408,9 +572,59 @@ func (tl TagsMap) MarshalText() ([]byte, error) {
 	return []byte(str), nil
 }
 
-//Write writes the marshaled text of a TagsMap to the file at path.
+// Tags returns the tags of the TagsMap sorted.
+func (tl TagsMap) Tags() []string {
+	tags := make([]string, 0, len(tl))
+	for k := range tl {
+		tags = append(tags, k)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// MarshalJSON produces a JSON object with the tags (sorted) as keys and
+// an array of node IDs as the value of each. As with Dex, the
+// encoding/json encoder is not used.
+func (tl TagsMap) MarshalJSON() ([]byte, error) {
+	var buf strings.Builder
+	buf.WriteRune('{')
+	for i, tag := range tl.Tags() {
+		if i > 0 {
+			buf.WriteRune(',')
+		}
+		buf.WriteString(`"` + json.Escape(tag) + `":[`)
+		for j, id := range tl[tag] {
+			if j > 0 {
+				buf.WriteRune(',')
+			}
+			if _, err := strconv.Atoi(id); err == nil {
+				buf.WriteString(id)
+				continue
+			}
+			buf.WriteString(`"` + json.Escape(id) + `"`)
+		}
+		buf.WriteRune(']')
+	}
+	buf.WriteRune('}')
+	return []byte(buf.String()), nil
+}
+
+// TSV renders the TagsMap as tab-separated values with one line for
+// every tag and node ID pair (sorted by tag).
+func (tl TagsMap) TSV() string {
+	var buf strings.Builder
+	for _, tag := range tl.Tags() {
+		for _, id := range tl[tag] {
+			buf.WriteString(tag + "\t" + id + "\n")
+		}
+	}
+	return buf.String()
+}
+
+//Write writes the marshaled text of a TagsMap to the file at path by
+//staging it to a temporary file and renaming it into place.
 func (tl TagsMap) Write(path string) error {
-	return file.Overwrite(path, tl.String())
+	return writeAll(map[string]string{path: tl.String()})
 }
 
 // UnmarshalText parses the tag lines items from the bytes buffer and
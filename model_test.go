@@ -128,6 +128,25 @@ func ExampleTagsMap_Marshal() {
 	// foo 34 23 4
 }
 
+func ExampleTagsMap_MarshalJSON() {
+	tl := keg.TagsMap{
+		`foo`:   {`34`, `23`, `4`},
+		`other`: {`2`},
+	}
+	buf, err := tl.MarshalJSON()
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(string(buf))
+	fmt.Print(tl.TSV())
+	// Output:
+	// {"foo":[34,23,4],"other":[2]}
+	// foo	34
+	// foo	23
+	// foo	4
+	// other	2
+}
+
 /*
 func ExampleTagsMap_Write() {
 	tl := keg.TagsMap{
@@ -139,3 +158,28 @@ func ExampleTagsMap_Write() {
 	// ignored
 }
 */
+
+func ExampleChange_Message() {
+	change := &keg.Change{
+		Op:    keg.OpEdited,
+		Nodes: keg.Dex{{N: 3, T: `Some title for 3`}},
+	}
+	fmt.Println(change.Message(""))
+	change.Op = keg.OpTagged
+	change.Note = `foo,bar`
+	fmt.Println(change.Message(""))
+	fmt.Println(change.Message(`{{.Op}} {{.IDs}} [{{.Note}}]`))
+	change = &keg.Change{Op: keg.OpImported, Nodes: keg.Dex{
+		{N: 13, T: `One`}, {N: 14, T: `Two`},
+		{N: 15, T: `Three`}, {N: 16, T: `Four`},
+	}}
+	fmt.Println(change.Message(""))
+	change = nil
+	fmt.Println(change.Message(""))
+	// Output:
+	// Edited 3: Some title for 3 <nil>
+	// Tagged 3 (foo,bar): Some title for 3 <nil>
+	// tagged 3 [foo,bar] <nil>
+	// Imported 13, 14, 15, 16: One; Two; Three; and 1 more <nil>
+	// Publish changes <nil>
+}
@@ -0,0 +1,790 @@
+package keg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	Z "github.com/rwxrob/bonzai/z"
+	"github.com/rwxrob/fs"
+	"github.com/rwxrob/fs/dir"
+	"github.com/rwxrob/fs/file"
+)
+
+const (
+	PublishNone   = `none`   // never publish
+	PublishCommit = `commit` // commit locally but never push
+	PublishPush   = `push`   // pull, commit, and push (default)
+)
+
+// PublishConf contains the settings from the publish section of the keg
+// file. All fields are optional:
+//
+//	publish:
+//	  mode: commit  # none, commit, or push (default)
+//	  batch: 5      # changed nodes before commit (default 1)
+//	  message: '{{.Verb}} {{.IDs}}: {{.Titles}}'
+//	  targets:
+//	    - type: git
+//	    - type: dir
+//	      path: /mnt/shared/kegs/zet
+//	    - type: archive
+//	      path: ~/backups/zet.tar.gz
+//
+// Batching allows several edits to be combined into a single commit.
+// Publish does nothing until at least Batch nodes have pending changes
+// (see Pending). PublishPending ignores Batch.
+//
+// Message is a text/template for git commit messages executed with the
+// Change being published (see Change.Message).
+//
+// Targets are the distribution targets to publish to, each of which
+// must have a Type registered in Publishers. If no targets are listed
+// only git is used.
+type PublishConf struct {
+	Mode    string          `yaml:"mode"`
+	Batch   int             `yaml:"batch"`
+	Message string          `yaml:"message"`
+	Targets []PublishTarget `yaml:"targets"`
+}
+
+// PublishTarget is a single distribution target from the targets list
+// of the publish section of the keg file. Path is only used by some
+// types. Relative paths are relative to the keg directory.
+type PublishTarget struct {
+	Type string `yaml:"type"`
+	Path string `yaml:"path"`
+}
+
+// Publisher is implemented by anything that can publish a keg to
+// a distribution target. The change describes the operation that
+// prompted the publish and is nil when not known (see PendingChange).
+// Implementations are free to ignore force, which is true when any
+// batching should be ignored.
+type Publisher interface {
+	Publish(kegpath string, change *Change, force bool) error
+	DryRun(kegpath string) (string, error)
+}
+
+// Publishers maps the type of a PublishTarget to a function returning
+// the Publisher for it. Add to it to support other targets.
+var Publishers = map[string]func(c PublishConf, t PublishTarget) Publisher{
+	`git`: func(c PublishConf, t PublishTarget) Publisher {
+		return GitPublisher{
+			Push:    c.Mode == PublishPush,
+			Batch:   c.Batch,
+			Message: c.Message,
+		}
+	},
+	`dir`: func(c PublishConf, t PublishTarget) Publisher {
+		return DirPublisher{Path: t.Path}
+	},
+	`archive`: func(c PublishConf, t PublishTarget) Publisher {
+		return ArchivePublisher{Path: t.Path}
+	},
+}
+
+// ReadPublishConf returns the PublishConf from the keg file within
+// kegpath with defaults applied for anything not set. An unreadable keg
+// file results in the defaults.
+func ReadPublishConf(kegpath string) PublishConf {
+	var conf PublishConf
+	if info, err := ReadKegInfo(kegpath); err == nil {
+		conf = info.Publish
+	}
+	if conf.Mode == "" {
+		conf.Mode = PublishPush
+	}
+	if conf.Batch < 1 {
+		conf.Batch = 1
+	}
+	if len(conf.Targets) == 0 {
+		conf.Targets = []PublishTarget{{Type: `git`}}
+	}
+	return conf
+}
+
+// Valid returns an error if the Mode is not one of the PublishNone,
+// PublishCommit, or PublishPush constants or if any of the Targets have
+// a type that is not in Publishers.
+func (c PublishConf) Valid() error {
+	switch c.Mode {
+	case PublishNone, PublishCommit, PublishPush:
+	default:
+		return fmt.Errorf(_BadPublishMode, c.Mode)
+	}
+	for _, t := range c.Targets {
+		if _, has := Publishers[t.Type]; !has {
+			return fmt.Errorf(_BadPublishTarget, t.Type)
+		}
+	}
+	return nil
+}
+
+// Publishers returns a Publisher for each of the Targets (see
+// Publishers) with any relative target paths made relative to kegpath.
+func (c PublishConf) Publishers(kegpath string) ([]Publisher, error) {
+	if err := c.Valid(); err != nil {
+		return nil, err
+	}
+	pubs := make([]Publisher, 0, len(c.Targets))
+	for _, t := range c.Targets {
+		if t.Path != "" {
+			t.Path = fs.Tilde2Home(t.Path)
+			if !filepath.IsAbs(t.Path) {
+				t.Path = filepath.Join(kegpath, t.Path)
+			}
+		}
+		pubs = append(pubs, Publishers[t.Type](c, t))
+	}
+	return pubs, nil
+}
+
+// Publish publishes the keg at kegpath location to its distribution
+// targets listed in the keg file under "publish" (see PublishConf). If
+// none are listed, this only involves finding the git repo containing
+// the keg and if found doing a git pull/add/commit/push (see
+// GitPublisher). The change describes what was done to the keg and is
+// used for the commit message.
+func Publish(kegpath string, change *Change) error {
+	return ReadPublishConf(kegpath).Publish(kegpath, change, false)
+}
+
+// PublishPending is the same as Publish but ignores any batch setting
+// so that all pending changes are published now. The commit message
+// describes the pending changes (see PendingChange).
+func PublishPending(kegpath string) error {
+	return ReadPublishConf(kegpath).Publish(kegpath, nil, true)
+}
+
+// Publish publishes the keg at kegpath to each of the Targets in order
+// stopping at the first error. Nothing is done if the Mode is
+// PublishNone or if the keg file blocks publishing when there are
+// problems with the files of nodes (see AssetsConf) and there are.
+func (c PublishConf) Publish(kegpath string, change *Change, force bool) error {
+	pubs, err := c.Publishers(kegpath)
+	if err != nil || c.Mode == PublishNone {
+		return err
+	}
+	if err := assetsBlocked(kegpath); err != nil {
+		return err
+	}
+	for _, p := range pubs {
+		if err := p.Publish(kegpath, change, force); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DryRun returns a description of what Publish would do for the keg at
+// kegpath without doing any of it by combining the DryRun of each of
+// the Targets.
+func (c PublishConf) DryRun(kegpath string) (string, error) {
+	pubs, err := c.Publishers(kegpath)
+	if err != nil {
+		return "", err
+	}
+	str := fmt.Sprintf("mode:    %v\n", c.Mode)
+	if c.Mode == PublishNone {
+		return str, nil
+	}
+	if err := assetsBlocked(kegpath); err != nil {
+		return str + fmt.Sprintf("blocked: %v\n", err), nil
+	}
+	for i, p := range pubs {
+		out, err := p.DryRun(kegpath)
+		if err != nil {
+			return "", err
+		}
+		str += fmt.Sprintf("target:  %v %v\n", c.Targets[i].Type, c.Targets[i].Path)
+		str += out
+	}
+	return str, nil
+}
+
+// ------------------------------- git --------------------------------
+
+// GitPublisher publishes by finding the git repo containing the keg
+// (see GitRepo) and if found doing a git pull/add/commit/push
+// (omitting the pull and push unless Push is true). Only the files
+// within the keg are added and committed, which matters when the keg
+// is a subdirectory of a larger repo (the docs convention). Nothing is
+// done if there are no pending changes or, unless forced, if there are
+// fewer than Batch changed nodes. Failures to pull are returned as
+// a SyncError (see Sync for resolving conflicts). Commit messages are
+// created from the Message template (see Change.Message) and describe
+// the change passed, or all pending changes when that change does not
+// cover all of them (usually because of batching).
+type GitPublisher struct {
+	Push    bool
+	Batch   int
+	Message string
+}
+
+// Publish fulfills the Publisher interface.
+func (g GitPublisher) Publish(kegpath string, change *Change, force bool) error {
+	root, sub, err := GitRepo(kegpath)
+	if err != nil {
+		return nil
+	}
+	changed, err := Pending(kegpath)
+	if err != nil || len(changed) == 0 {
+		return err
+	}
+	if !force && batchCount(changed) < g.Batch {
+		return nil
+	}
+	if !change.Covers(ChangedNodes(changed)) {
+		change = PendingChange(kegpath, changed)
+	}
+	msg, err := change.Message(g.Message)
+	if err != nil {
+		return err
+	}
+	if g.Push {
+		if out, err := gitCombined(root, `pull`, `--no-rebase`, `--no-edit`); err != nil {
+			return SyncErrorFrom(out)
+		}
+	}
+	if err := Z.Exec(append([]string{`git`, `-C`, root}, gitAdd(sub)...)...); err != nil {
+		return err
+	}
+	if err := Z.Exec(
+		`git`, `-C`, root, `commit`, `-m`, msg, `--`, sub,
+	); err != nil {
+		return err
+	}
+	if g.Push {
+		return Z.Exec(`git`, `-C`, root, `push`)
+	}
+	return nil
+}
+
+// DryRun fulfills the Publisher interface by listing the repo, batch
+// status, commit message, and each pending change in git status
+// --porcelain format.
+func (g GitPublisher) DryRun(kegpath string) (string, error) {
+	root, sub, err := GitRepo(kegpath)
+	if err != nil {
+		return fmt.Sprintf("repo:    none (%v)\n", err), nil
+	}
+	changed, err := Pending(kegpath)
+	if err != nil {
+		return "", err
+	}
+	var str string
+	str += fmt.Sprintf("repo:    %v (keg: %v)\n", root, sub)
+	str += fmt.Sprintf("push:    %v\n", g.Push)
+	str += fmt.Sprintf("batch:   %v of %v\n", batchCount(changed), g.Batch)
+	msg, err := PendingChange(kegpath, changed).Message(g.Message)
+	if err != nil {
+		return "", err
+	}
+	str += fmt.Sprintf("message: %v\n", msg)
+	for _, line := range changed {
+		str += line + "\n"
+	}
+	return str, nil
+}
+
+// GitRepo returns the root directory of the git repo containing the keg
+// at kegpath (no matter what the current working directory is) along
+// with the path to the keg relative to that root. The relative path is
+// "." when the keg is the root of the repo and something else (usually
+// "docs") when the keg is a subdirectory of it. An error is returned if
+// the keg is not within a git repo.
+func GitRepo(kegpath string) (root, sub string, err error) {
+	out, err := gitOut(kegpath, `rev-parse`, `--show-toplevel`, `--show-prefix`)
+	if err != nil {
+		return "", "", fmt.Errorf(_NotGitRepo, kegpath)
+	}
+	lines := strings.Split(out, "\n")
+	root = strings.TrimSpace(lines[0])
+	if len(lines) > 1 {
+		sub = strings.TrimSuffix(strings.TrimSpace(lines[1]), `/`)
+	}
+	if sub == "" {
+		sub = `.`
+	}
+	return root, filepath.FromSlash(sub), nil
+}
+
+// PendingChange returns a Change describing all of the pending changes
+// (see Pending) in the keg at kegpath. The Op is OpCreated, OpEdited, or
+// OpDeleted if all of the changed nodes were changed that way and
+// OpUpdated otherwise. Titles are looked up from the dex and are empty
+// for deleted nodes.
+func PendingChange(kegpath string, pending []string) *Change {
+	change := &Change{Op: OpUpdated}
+	dex, _ := ReadDex(kegpath)
+	var ops []string
+	seen := map[string]bool{}
+	for _, line := range pending {
+		id := pendingNode(line)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		n, _ := strconv.Atoi(id)
+		entry := &DexEntry{N: n}
+		if dex != nil {
+			if found := dex.Lookup(n); found != nil {
+				entry = found
+			}
+		}
+		change.Nodes = append(change.Nodes, entry)
+		switch {
+		case line[0] == '?' || line[0] == 'A':
+			ops = append(ops, OpCreated)
+		case line[0] == 'D' || line[1] == 'D':
+			ops = append(ops, OpDeleted)
+		default:
+			ops = append(ops, OpEdited)
+		}
+	}
+	for i, op := range ops {
+		if i > 0 && op != ops[0] {
+			return change
+		}
+	}
+	if len(ops) > 0 {
+		change.Op = ops[0]
+	}
+	return change
+}
+
+// Pending returns the uncommitted changes within the keg at kegpath as
+// lines from git status --porcelain with all paths relative to kegpath.
+// An empty slice is returned if there are none. An error is returned
+// if kegpath is not within a git repo.
+func Pending(kegpath string) ([]string, error) {
+	_, sub, err := GitRepo(kegpath)
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.ToSlash(sub) + `/`
+	out, err := gitOut(kegpath, `status`, `--porcelain`, `--`, `.`,
+		`:(exclude)`+LockFile)
+	if err != nil {
+		return nil, err
+	}
+	changed := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		changed = append(changed, line[:3]+strings.TrimPrefix(line[3:], prefix))
+	}
+	return changed, nil
+}
+
+// ChangedNodes returns the unique node IDs (as strings) referred to by
+// the paths in the lines returned by Pending in the order first seen.
+func ChangedNodes(pending []string) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, line := range pending {
+		id := pendingNode(line)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// batchCount returns the number of changes counted toward a batch,
+// which is the number of ChangedNodes but with any other pending changes
+// (dex/tags, for example) counting as one.
+func batchCount(pending []string) int {
+	n := len(ChangedNodes(pending))
+	if n == 0 && len(pending) > 0 {
+		return 1
+	}
+	return n
+}
+
+// pendingNode returns the node ID from the path of a line returned by
+// Pending or an empty string if the path is not within a node.
+func pendingNode(line string) string {
+	if len(line) < 4 {
+		return ""
+	}
+	id, _, _ := strings.Cut(line[3:], `/`)
+	if !fs.NameIsInt(id) {
+		return ""
+	}
+	return id
+}
+
+// gitAdd returns the arguments to git (run from the root of the repo)
+// to stage every change within sub (the keg directory relative to the
+// root) except to the lock file (see Lock) which may be held by another
+// process at the time.
+func gitAdd(sub string) []string {
+	lock := `:(exclude)` + path.Join(filepath.ToSlash(sub), LockFile)
+	return []string{`add`, `-A`, `--`, sub, lock}
+}
+
+// gitOut runs git from within dir and returns its standard output.
+func gitOut(dir string, args ...string) (string, error) {
+	cmd := exec.Command(`git`, append([]string{`-C`, dir}, args...)...)
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// kegFiles returns the slash-separated paths, relative to kegpath, of
+// every regular file within the keg skipping any .git directory and the
+// lock file (see Lock).
+func kegFiles(kegpath string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(kegpath,
+		func(path string, d iofs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && d.Name() == `.git` {
+				return filepath.SkipDir
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(kegpath, path)
+			if err != nil {
+				return err
+			}
+			if rel = filepath.ToSlash(rel); rel != LockFile {
+				files = append(files, rel)
+			}
+			return nil
+		})
+	return files, err
+}
+
+// outsideKeg returns an error if path is empty, is kegpath itself or
+// within it (which would cause the keg to be published into itself), or
+// contains it (which would cause a dir target to remove everything
+// around the keg, see DirPublisher).
+func outsideKeg(kegpath, path, typ string) error {
+	if path == "" {
+		return fmt.Errorf(_PublishNoPath, typ)
+	}
+	kabs, err := filepath.Abs(kegpath)
+	if err != nil {
+		return err
+	}
+	pabs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(kabs, pabs)
+	if err != nil {
+		return err
+	}
+	if !isParentRel(rel) {
+		return fmt.Errorf(_PublishInsideKeg, path)
+	}
+	if rel, err = filepath.Rel(pabs, kabs); err != nil {
+		return err
+	}
+	if !isParentRel(rel) {
+		return fmt.Errorf(_PublishAroundKeg, path)
+	}
+	return nil
+}
+
+// isParentRel returns true if the relative path (see filepath.Rel)
+// leads out of the directory it is relative to.
+func isParentRel(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	return rel == `..` || strings.HasPrefix(rel, `../`)
+}
+
+// ------------------------------- dir --------------------------------
+
+// MirrorMarker is the name of the file that marks a directory as
+// a mirror of a keg (see DirPublisher). Add one to an existing mirror
+// (touch .kegmirror) to allow publishing to it.
+const MirrorMarker = `.kegmirror`
+
+// DirPublisher publishes by mirroring the keg into the directory at
+// Path (usually on a shared file system) in a way similar to rsync
+// --delete. Only files that are missing or have a different size or
+// modification time are copied and anything in Path that is no longer
+// in the keg is removed. Modification times are preserved so that the
+// mirror can itself be indexed accurately. Any .git directory is
+// skipped in both. To keep from removing anything that is not a mirror
+// the MirrorMarker file is written into Path and nothing is done if
+// Path is not empty and does not have one.
+type DirPublisher struct {
+	Path string
+}
+
+// changes returns the relative paths of the files to copy into the
+// mirror and those to remove from it.
+func (p DirPublisher) changes(kegpath string) (copies, removes []string, err error) {
+	files, err := kegFiles(kegpath)
+	if err != nil {
+		return
+	}
+	have := map[string]bool{}
+	for _, rel := range files {
+		have[rel] = true
+		for d := path.Dir(rel); d != `.`; d = path.Dir(d) {
+			have[d] = true
+		}
+		src, err := os.Stat(filepath.Join(kegpath, rel))
+		if err != nil {
+			return nil, nil, err
+		}
+		dst, err := os.Stat(filepath.Join(p.Path, rel))
+		if err != nil || dst.Size() != src.Size() ||
+			!dst.ModTime().Equal(src.ModTime()) {
+			copies = append(copies, rel)
+		}
+	}
+	if fs.NotExists(p.Path) || dir.IsEmpty(p.Path) {
+		return
+	}
+	if fs.NotExists(filepath.Join(p.Path, MirrorMarker)) {
+		return nil, nil, fmt.Errorf(_PublishNotMirror, MirrorMarker, p.Path)
+	}
+	err = filepath.WalkDir(p.Path,
+		func(path string, d iofs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == p.Path {
+				return nil
+			}
+			if d.IsDir() && d.Name() == `.git` {
+				return filepath.SkipDir
+			}
+			rel, err := filepath.Rel(p.Path, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if !have[rel] && rel != MirrorMarker {
+				removes = append(removes, rel)
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		})
+	return
+}
+
+// Publish fulfills the Publisher interface.
+func (p DirPublisher) Publish(kegpath string, _ *Change, _ bool) error {
+	if err := outsideKeg(kegpath, p.Path, `dir`); err != nil {
+		return err
+	}
+	copies, removes, err := p.changes(kegpath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p.Path, 0755); err != nil {
+		return err
+	}
+	if err := file.Touch(filepath.Join(p.Path, MirrorMarker)); err != nil {
+		return err
+	}
+	for _, rel := range removes {
+		if err := os.RemoveAll(filepath.Join(p.Path, rel)); err != nil {
+			return err
+		}
+	}
+	for _, rel := range copies {
+		err := copyFile(
+			filepath.Join(kegpath, rel), filepath.Join(p.Path, rel),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DryRun fulfills the Publisher interface by listing each file that
+// would be copied or removed.
+func (p DirPublisher) DryRun(kegpath string) (string, error) {
+	if err := outsideKeg(kegpath, p.Path, `dir`); err != nil {
+		return "", err
+	}
+	copies, removes, err := p.changes(kegpath)
+	if err != nil {
+		return "", err
+	}
+	var str string
+	for _, rel := range removes {
+		str += "remove  " + rel + "\n"
+	}
+	for _, rel := range copies {
+		str += "copy    " + rel + "\n"
+	}
+	return str, nil
+}
+
+// copyFile copies the file at src to dst creating any needed parent
+// directories and preserving the permissions and modification time.
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// ----------------------------- archive ------------------------------
+
+// ArchivePublisher publishes by writing every file in the keg (except
+// any .git directory) into a single archive file at Path. The format
+// is determined by the Path suffix: .zip, .tar, or .tar.gz (or .tgz).
+// The archive is first written to a temporary file in the same
+// directory and then renamed so that readers never see a partial
+// archive.
+type ArchivePublisher struct {
+	Path string
+}
+
+// Publish fulfills the Publisher interface.
+func (p ArchivePublisher) Publish(kegpath string, _ *Change, _ bool) error {
+	if err := outsideKeg(kegpath, p.Path, `archive`); err != nil {
+		return err
+	}
+	files, err := kegFiles(kegpath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.Path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p.Path), `.`+filepath.Base(p.Path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	switch {
+	case strings.HasSuffix(p.Path, `.zip`):
+		err = writeZip(tmp, kegpath, files)
+	case strings.HasSuffix(p.Path, `.tar`):
+		err = writeTar(tmp, kegpath, files)
+	case strings.HasSuffix(p.Path, `.tar.gz`), strings.HasSuffix(p.Path, `.tgz`):
+		gz := gzip.NewWriter(tmp)
+		err = writeTar(gz, kegpath, files)
+		if err == nil {
+			err = gz.Close()
+		}
+	default:
+		err = fmt.Errorf(_BadArchiveType, p.Path)
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.Path)
+}
+
+// DryRun fulfills the Publisher interface.
+func (p ArchivePublisher) DryRun(kegpath string) (string, error) {
+	if err := outsideKeg(kegpath, p.Path, `archive`); err != nil {
+		return "", err
+	}
+	files, err := kegFiles(kegpath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("write   %v (%v files)\n", p.Path, len(files)), nil
+}
+
+func writeZip(w io.Writer, kegpath string, files []string) error {
+	zw := zip.NewWriter(w)
+	for _, rel := range files {
+		info, err := os.Stat(filepath.Join(kegpath, rel))
+		if err != nil {
+			return err
+		}
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		hdr.Method = zip.Deflate
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if err := copyInto(fw, filepath.Join(kegpath, rel)); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeTar(w io.Writer, kegpath string, files []string) error {
+	tw := tar.NewWriter(w)
+	for _, rel := range files {
+		info, err := os.Stat(filepath.Join(kegpath, rel))
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if err := copyInto(tw, filepath.Join(kegpath, rel)); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// copyInto copies the content of the file at path into w.
+func copyInto(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
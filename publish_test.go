@@ -0,0 +1,79 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleReadPublishConf() {
+	conf := keg.ReadPublishConf(`testdata/samplekeg`)
+	fmt.Println(conf.Mode, conf.Batch, conf.Targets)
+	// Output:
+	// push 1 [{git }]
+}
+
+func ExampleChangedNodes() {
+	pending := []string{
+		` M 3/README.md`,
+		`?? 12/README.md`,
+		` M dex/changes.md`,
+		`?? 3/image.png`,
+	}
+	fmt.Println(keg.ChangedNodes(pending))
+	// Output:
+	// [3 12]
+}
+
+func ExampleDirPublisher() {
+	dir, err := os.MkdirTemp("", "kegmirror")
+	if err != nil {
+		fmt.Println(err)
+	}
+	defer os.RemoveAll(dir)
+	pub := keg.DirPublisher{Path: filepath.Join(dir, `samplekeg`)}
+	if err := pub.Publish(`testdata/samplekeg`, nil, false); err != nil {
+		fmt.Println(err)
+	}
+	out, _ := pub.DryRun(`testdata/samplekeg`)
+	fmt.Printf("%q\n", out)
+	fmt.Println(keg.Last(pub.Path).N)
+	// Output:
+	// ""
+	// 12
+}
+
+func ExampleArchivePublisher_inside() {
+	pub := keg.ArchivePublisher{Path: `testdata/samplekeg/keg.zip`}
+	fmt.Println(pub.Publish(`testdata/samplekeg`, nil, false))
+	// Output:
+	// publish target path must be outside of keg: testdata/samplekeg/keg.zip
+}
+
+func ExampleDirPublisher_safety() {
+	dir, _ := os.MkdirTemp("", "kegmirror")
+	defer os.RemoveAll(dir)
+	kegdir := filepath.Join(dir, `keg`)
+	os.MkdirAll(filepath.Join(kegdir, `1`), 0755)
+	os.WriteFile(filepath.Join(kegdir, `1`, `README.md`), []byte("# One\n"), 0644)
+	other := filepath.Join(dir, `other`)
+	os.MkdirAll(other, 0755)
+	os.WriteFile(filepath.Join(other, `precious`), []byte(`x`), 0644)
+
+	for _, path := range []string{dir, other, filepath.Join(kegdir, `..mirror`)} {
+		pub := keg.DirPublisher{Path: path}
+		err := pub.Publish(kegdir, nil, false)
+		msg, _, _ := strings.Cut(fmt.Sprint(err), `:`)
+		fmt.Println(msg)
+	}
+	_, err := os.Stat(filepath.Join(other, `precious`))
+	fmt.Println(err)
+	// Output:
+	// publish target path must not contain keg
+	// publish target is not empty and has no .kegmirror file (not a keg mirror)
+	// publish target path must be outside of keg
+	// <nil>
+}
@@ -0,0 +1,138 @@
+package keg
+
+import (
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rwxrob/fs"
+	"github.com/rwxrob/fs/file"
+	"github.com/rwxrob/keg/kegml"
+)
+
+// Modes of Send.
+const (
+	SendMove = `move` // remove node from source keg
+	SendCopy = `copy` // leave node in source keg as is
+	SendStub = `stub` // replace node in source keg with forwarding stub
+)
+
+// Send sends the node with the given id from one keg to another where
+// it is given a new ID (see MakeNode) and added to the dex. All files
+// within the node directory are copied. Links within the README.md to
+// other nodes of the source keg (../ID) are changed to cross-keg links
+// (keg:NAME/ID) so that they continue to work and any cross-keg links
+// to nodes within the target keg are changed to regular links. What
+// happens to the original node depends on the mode (SendMove,
+// SendCopy, or SendStub). A moved node is also taken out of the
+// dex/tags file of the source keg. A stub keeps the original title and
+// ID (and tags) and only contains a link to the new location. The
+// DexEntry of the new node is returned.
+func Send(from, to *Local, id int, mode string) (*DexEntry, error) {
+	switch mode {
+	case SendMove, SendCopy, SendStub:
+	default:
+		return nil, fmt.Errorf(_BadSendMode, mode)
+	}
+	src := filepath.Join(from.Path, strconv.Itoa(id))
+	if !fs.IsDir(src) {
+		return nil, fmt.Errorf(_NodeNotFound, id)
+	}
+	if sameDir(from.Path, to.Path) {
+		return nil, fmt.Errorf(_SendSameKeg, to.Name)
+	}
+	title, err := kegml.ReadTitle(src)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := MakeNode(to.Path)
+	if err != nil {
+		return nil, err
+	}
+	dst := filepath.Join(to.Path, entry.ID())
+	if err := copyNode(src, dst, from.Name, to.Name); err != nil {
+		os.RemoveAll(dst)
+		return nil, err
+	}
+	if err := DexUpdate(to.Path, entry); err != nil {
+		return entry, err
+	}
+
+	orig := &DexEntry{N: id, T: title}
+	switch mode {
+	case SendMove:
+		if err := os.RemoveAll(src); err != nil {
+			return entry, err
+		}
+		if err := DexRemove(from.Path, orig); err != nil {
+			return entry, err
+		}
+		return entry, retag(from.Path, id, nil, true)
+	case SendStub:
+		if err := os.RemoveAll(src); err != nil {
+			return entry, err
+		}
+		stub := fmt.Sprintf(_SendStub, title, title,
+			Link{Keg: to.Name, N: entry.N})
+		if err := file.Overwrite(filepath.Join(src, `README.md`), stub); err != nil {
+			return entry, err
+		}
+		return entry, DexUpdate(from.Path, orig)
+	}
+	return entry, nil
+}
+
+// copyNode copies every file of the node directory src into dst and
+// rewrites the links of its README.md for its new keg (see Send).
+func copyNode(src, dst, from, to string) error {
+	err := filepath.WalkDir(src, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return copyFile(path, filepath.Join(dst, rel))
+	})
+	if err != nil {
+		return err
+	}
+	readme := filepath.Join(dst, `README.md`)
+	buf, err := os.ReadFile(readme)
+	if err != nil {
+		return err
+	}
+	text := RelinkNode(string(buf), from, to)
+	if text == string(buf) {
+		return nil
+	}
+	return file.Overwrite(readme, text)
+}
+
+// RelinkNode changes the links within the KEGML text of a node moved
+// from the keg named from to the keg named to. Regular links (../ID)
+// become cross-keg links to from (keg:FROM/ID) and cross-keg links to
+// to become regular links.
+func RelinkNode(text, from, to string) string {
+	return LinkExp.ReplaceAllStringFunc(text, func(m string) string {
+		l, ok := ParseLink(m[2 : len(m)-1])
+		switch {
+		case !ok:
+			return m
+		case l.Keg == "":
+			l.Keg = from
+		case l.Keg == to:
+			l.Keg = ""
+		default:
+			return m
+		}
+		return `](` + l.String() + `)`
+	})
+}
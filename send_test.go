@@ -0,0 +1,42 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleRelinkNode() {
+	text := "[a](../2) [b](keg:team/4) [c](keg:other/5) [d](https://x)\n"
+	fmt.Print(keg.RelinkNode(text, `mine`, `team`))
+	// Output:
+	// [a](keg:mine/2) [b](../4) [c](keg:other/5) [d](https://x)
+}
+
+func ExampleSend_move() {
+	dir, _ := os.MkdirTemp("", "kegsend")
+	defer os.RemoveAll(dir)
+	from := &keg.Local{Name: `mine`, Path: filepath.Join(dir, `mine`)}
+	to := &keg.Local{Name: `team`, Path: filepath.Join(dir, `team`)}
+	for _, k := range []*keg.Local{from, to} {
+		os.MkdirAll(filepath.Join(k.Path, `0`), 0755)
+		os.WriteFile(filepath.Join(k.Path, `keg`), []byte("title: "+k.Name+"\n"), 0644)
+		os.WriteFile(filepath.Join(k.Path, `0`, `README.md`), []byte("# Zero\n"), 0644)
+		keg.DexUpdate(k.Path, &keg.DexEntry{N: 0})
+	}
+	os.MkdirAll(filepath.Join(from.Path, `1`), 0755)
+	os.WriteFile(filepath.Join(from.Path, `1`, `README.md`), []byte("# One\n"), 0644)
+	keg.DexUpdate(from.Path, &keg.DexEntry{N: 1})
+	os.WriteFile(filepath.Join(from.Path, `dex`, `tags`), []byte("go 0 1\nsend 1\n"), 0644)
+
+	entry, err := keg.Send(from, to, 1, keg.SendMove)
+	fmt.Println(entry.N, err)
+	tags, _ := keg.ReadTags(from.Path)
+	fmt.Println(tags[`go`], tags[`send`])
+
+	// Output:
+	// 1 <nil>
+	// [0] []
+}
@@ -0,0 +1,437 @@
+package keg
+
+import (
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rwxrob/fs"
+	"github.com/rwxrob/fs/file"
+	"github.com/rwxrob/keg/kegml"
+)
+
+// NodePart is a part of a node split from it by SplitText.
+type NodePart struct {
+	Title string // from the heading (or the original title and a number)
+	Text  string // full README.md text of the part including the title
+}
+
+// footnoteExp matches the beginning of a footnote definition capturing
+// its label.
+var footnoteExp = regexp.MustCompile(`^\[\^([^\]\s]+)\]:`)
+
+// footnoteRefExp matches any reference to a footnote (including the
+// beginning of its definition) capturing its label.
+var footnoteRefExp = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+
+// SplitText splits the KEGML text of a node into parts at every
+// second-level heading (## ) and separator (---) that is not within
+// a fenced block. The text before the first of these (which includes
+// the title) is returned as the intro. Each part is given the heading
+// as its title (or the original title followed by the part number if
+// it begins with a separator) and any deeper headings within it are
+// promoted one level. Footnotes are copied to the intro and every part
+// that refers to them. An empty list is returned if there is nothing to
+// split.
+func SplitText(text string) (intro string, parts []NodePart) {
+	body, foot := splitFootnotes(text)
+	notes := footnotes(foot)
+
+	title := strings.TrimPrefix(strings.SplitN(body, "\n", 2)[0], `# `)
+	var cur *strings.Builder
+	var curtitle string
+	var introbuf strings.Builder
+	var fence string
+	add := func() {
+		if cur == nil {
+			return
+		}
+		text := strings.TrimSpace(cur.String())
+		if text == "" && curtitle == "" {
+			return
+		}
+		if curtitle == "" {
+			curtitle = fmt.Sprintf(_SplitPart, title, len(parts)+1)
+		}
+		text = `# ` + curtitle + "\n\n" + text
+		parts = append(parts, NodePart{
+			Title: curtitle,
+			Text:  withFootnotes(strings.TrimRight(text, "\n")+"\n", notes),
+		})
+	}
+
+	for _, line := range strings.SplitAfter(body, "\n") {
+		bare := strings.TrimRight(line, "\r\n")
+		switch {
+		case fence != "":
+			if endsFence(bare, fence) {
+				fence = ""
+			}
+		case fenceOf(bare) != "":
+			fence = fenceOf(bare)
+		case strings.HasPrefix(bare, `## `):
+			add()
+			cur, curtitle = new(strings.Builder), strings.TrimSpace(bare[3:])
+			continue
+		case bare == `---`:
+			add()
+			cur, curtitle = new(strings.Builder), ""
+			continue
+		case cur != nil && strings.HasPrefix(bare, `###`):
+			line = line[1:]
+		}
+		if cur == nil {
+			introbuf.WriteString(line)
+			continue
+		}
+		cur.WriteString(line)
+	}
+	add()
+
+	intro = strings.TrimRight(introbuf.String(), "\n") + "\n"
+	return withFootnotes(intro, notes), parts
+}
+
+// fenceOf returns the fence (three or more backticks or tildes) that
+// begins the line or an empty string if it does not begin one.
+func fenceOf(line string) string {
+	if !strings.HasPrefix(line, "```") && !strings.HasPrefix(line, `~~~`) {
+		return ""
+	}
+	return line[:len(line)-len(strings.TrimLeft(line, line[:1]))]
+}
+
+// endsFence returns true if the line closes the fenced block begun with
+// fence.
+func endsFence(line, fence string) bool {
+	return strings.HasPrefix(line, fence) &&
+		strings.Trim(line, fence[:1]+" \t") == ""
+}
+
+// footnotes splits the footnotes text (see splitFootnotes) into the
+// individual definitions (including any continuation lines) by label.
+func footnotes(foot string) map[string]string {
+	notes := map[string]string{}
+	var label string
+	for _, line := range strings.SplitAfter(foot, "\n") {
+		if m := footnoteExp.FindStringSubmatch(line); m != nil {
+			label = m[1]
+		}
+		if label != "" && strings.TrimSpace(line) != "" {
+			notes[label] += line
+		}
+	}
+	return notes
+}
+
+// withFootnotes returns the text with every footnote of notes that it
+// refers to added to the end (in the order first referred to).
+func withFootnotes(text string, notes map[string]string) string {
+	var foot strings.Builder
+	seen := map[string]bool{}
+	for _, m := range footnoteRefExp.FindAllStringSubmatch(text, -1) {
+		if note, has := notes[m[1]]; has && !seen[m[1]] {
+			seen[m[1]] = true
+			foot.WriteString(strings.TrimRight(note, "\n") + "\n")
+		}
+	}
+	if foot.Len() == 0 {
+		return text
+	}
+	return text + "\n" + foot.String()
+}
+
+// Split splits the node with the given id in the keg at kegpath into
+// several new nodes (see SplitText) and replaces the parts in the
+// original node with an include list of the new nodes so that links to
+// it still lead to all of its content. The new nodes have the same tags
+// as the original. Other files within the node directory are left with
+// the original and links to them from the new nodes changed to match
+// (see RebaseFileLinks). The dex is updated and the DexEntry of the original
+// followed by those of the new nodes are returned.
+func Split(kegpath string, id int) (Dex, error) {
+	unlock, err := Lock(kegpath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	readme := filepath.Join(kegpath, strconv.Itoa(id), `README.md`)
+	buf, err := os.ReadFile(readme)
+	if err != nil {
+		return nil, fmt.Errorf(_NodeNotFound, id)
+	}
+	intro, parts := SplitText(string(buf))
+	if len(parts) == 0 {
+		return nil, fmt.Errorf(_NothingToSplit, id)
+	}
+
+	orig := &DexEntry{N: id}
+	changed := Dex{orig}
+	for _, part := range parts {
+		entry, err := makeNode(kegpath)
+		if err != nil {
+			return changed, err
+		}
+		path := filepath.Join(kegpath, entry.ID(), `README.md`)
+		if err := file.Overwrite(path, RebaseFileLinks(part.Text, id)); err != nil {
+			return changed, err
+		}
+		entry.T = part.Title
+		changed = append(changed, entry)
+	}
+
+	body, foot := splitFootnotes(intro)
+	text := strings.TrimRight(body, "\n") + "\n\n" + changed[1:].AsIncludes()
+	if foot != "" {
+		text += "\n" + foot
+	}
+	if err := file.Overwrite(readme, text); err != nil {
+		return changed, err
+	}
+
+	if err := retag(kegpath, id, changed[1:], false); err != nil {
+		return changed, err
+	}
+	for _, entry := range changed {
+		if err := updateDex(kegpath, entry); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// Merge appends the node with the id from into the node with the id
+// into (in the keg at kegpath) and removes it. Its title becomes
+// a second-level heading (with any headings within it demoted one
+// level) and its footnotes are added to those of into (with labels
+// prefixed by its ID to keep them unique). Other files within its node
+// directory are moved as well unless one of the same name already
+// exists (which is an error before anything is changed). Every link to
+// from within the keg is changed to a link to into and every tag of
+// from is given to into. The dex is updated and the DexEntry of into
+// followed by those of any other nodes changed are returned.
+func Merge(kegpath string, into, from int) (Dex, error) {
+	if into == from {
+		return nil, fmt.Errorf(_MergeSame, into)
+	}
+	unlock, err := Lock(kegpath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	dst := filepath.Join(kegpath, strconv.Itoa(into))
+	src := filepath.Join(kegpath, strconv.Itoa(from))
+	for _, dir := range []string{dst, src} {
+		if !fs.Exists(filepath.Join(dir, `README.md`)) {
+			return nil, fmt.Errorf(_NodeNotFound, filepath.Base(dir))
+		}
+	}
+
+	// check for conflicting files before changing anything
+	var others []string
+	err = filepath.WalkDir(src, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if rel == `README.md` {
+			return nil
+		}
+		if fs.Exists(filepath.Join(dst, rel)) {
+			return fmt.Errorf(_MergeConflict, rel, into)
+		}
+		others = append(others, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	abuf, err := os.ReadFile(filepath.Join(dst, `README.md`))
+	if err != nil {
+		return nil, err
+	}
+	bbuf, err := os.ReadFile(filepath.Join(src, `README.md`))
+	if err != nil {
+		return nil, err
+	}
+	text := MergeText(string(abuf), string(bbuf), strconv.Itoa(from))
+	text = RedirectLinks(text, from, into)
+	if err := file.Overwrite(filepath.Join(dst, `README.md`), text); err != nil {
+		return nil, err
+	}
+	for _, rel := range others {
+		if err := copyFile(filepath.Join(src, rel), filepath.Join(dst, rel)); err != nil {
+			return nil, err
+		}
+	}
+	title, _ := kegml.ReadTitle(src)
+	if err := os.RemoveAll(src); err != nil {
+		return nil, err
+	}
+
+	entry := &DexEntry{N: into}
+	changed := Dex{entry}
+	dirs, _, _ := NodePaths(kegpath)
+	for _, d := range dirs {
+		n, err := strconv.Atoi(d.Info.Name())
+		if err != nil || n == into {
+			continue
+		}
+		readme := filepath.Join(d.Path, `README.md`)
+		buf, err := os.ReadFile(readme)
+		if err != nil {
+			continue
+		}
+		text := RedirectLinks(string(buf), from, into)
+		if text == string(buf) {
+			continue
+		}
+		if err := file.Overwrite(readme, text); err != nil {
+			return changed, err
+		}
+		changed = append(changed, &DexEntry{N: n})
+	}
+
+	if err := retag(kegpath, from, Dex{entry}, true); err != nil {
+		return changed, err
+	}
+	if err := removeDex(kegpath, &DexEntry{N: from, T: title}); err != nil {
+		return changed, err
+	}
+	for _, e := range changed {
+		if err := updateDex(kegpath, e); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// MergeText returns the KEGML text of node b appended to that of node
+// a (see Merge). The labels of the footnotes of b are prefixed with
+// prefix (usually its ID) and a dash.
+func MergeText(a, b, prefix string) string {
+	abody, afoot := splitFootnotes(a)
+	bbody, bfoot := splitFootnotes(b)
+
+	relabel := func(s string) string {
+		return footnoteRefExp.ReplaceAllString(s, `[^`+prefix+`-${1}]`)
+	}
+	if bfoot != "" {
+		bbody, bfoot = relabel(bbody), relabel(bfoot)
+	}
+
+	var fence string
+	var buf strings.Builder
+	for _, line := range strings.SplitAfter(strings.Trim(bbody, "\n"), "\n") {
+		bare := strings.TrimRight(line, "\r\n")
+		switch {
+		case fence != "":
+			if endsFence(bare, fence) {
+				fence = ""
+			}
+		case fenceOf(bare) != "":
+			fence = fenceOf(bare)
+		case strings.HasPrefix(bare, `#`):
+			line = `#` + line
+		}
+		buf.WriteString(line)
+	}
+
+	text := strings.TrimRight(abody, "\n") + "\n\n" +
+		strings.TrimRight(buf.String(), "\n") + "\n"
+	foot := strings.Trim(afoot, "\n")
+	if f := strings.Trim(bfoot, "\n"); f != "" {
+		if foot != "" {
+			foot += "\n"
+		}
+		foot += f
+	}
+	if foot != "" {
+		text += "\n" + foot + "\n"
+	}
+	return text
+}
+
+// RedirectLinks changes every link to the node from (../FROM) within
+// the KEGML text into a link to the node to (../TO) including links to
+// files within its node directory (../FROM/fig.png).
+func RedirectLinks(text string, from, to int) string {
+	text = LinkExp.ReplaceAllStringFunc(text, func(m string) string {
+		l, ok := ParseLink(m[2 : len(m)-1])
+		if !ok || l.Keg != "" || l.N != from {
+			return m
+		}
+		l.N = to
+		return `](` + l.String() + `)`
+	})
+	prefix := `../` + strconv.Itoa(from) + `/`
+	return MapLinkTargets(text, func(target string) string {
+		if !strings.HasPrefix(target, prefix) || len(target) == len(prefix) {
+			return target
+		}
+		return `../` + strconv.Itoa(to) + `/` + target[len(prefix):]
+	})
+}
+
+// RebaseFileLinks changes every link to a local file (see LocalFile)
+// within the KEGML text into a link to the same file within the
+// directory of the node with the given id (../ID/file) for text that is
+// moved out of that node.
+func RebaseFileLinks(text string, id int) string {
+	return MapLinkTargets(text, func(target string) string {
+		if _, ok := LocalFile(target); !ok {
+			return target
+		}
+		return `../` + strconv.Itoa(id) + `/` + strings.TrimPrefix(target, `./`)
+	})
+}
+
+// retag gives every node in nodes the tags of the node with the given
+// id (and, if remove is true, takes them from it) in the dex/tags file
+// of the keg at kegpath (if it has one).
+func retag(kegpath string, id int, nodes Dex, remove bool) error {
+	tmap, err := ReadTags(kegpath)
+	if err != nil {
+		return nil
+	}
+	sid := strconv.Itoa(id)
+	var changed bool
+	for tag, ids := range tmap {
+		var has bool
+		kept := []string{}
+		for _, i := range ids {
+			if i == sid {
+				has = true
+				if remove {
+					continue
+				}
+			}
+			kept = append(kept, i)
+		}
+		if !has {
+			continue
+		}
+		for _, e := range nodes {
+			var dup bool
+			for _, i := range kept {
+				dup = dup || i == e.ID()
+			}
+			if !dup {
+				kept = append(kept, e.ID())
+			}
+		}
+		tmap[tag] = kept
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return tmap.Write(filepath.Join(kegpath, `dex`, `tags`))
+}
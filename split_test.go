@@ -0,0 +1,87 @@
+package keg_test
+
+import (
+	"fmt"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleSplitText() {
+	text := "# Big\n\nIntro[^1].\n\n## One\n\nFirst.\n\n### Deeper\n\n" +
+		"```\n## not a heading\n```\n\n---\n\nSecond[^1].\n\n[^1]: Note\n"
+	intro, parts := keg.SplitText(text)
+	fmt.Print(intro)
+	for _, p := range parts {
+		fmt.Printf("--- %v\n%v", p.Title, p.Text)
+	}
+
+	// Output:
+	// # Big
+	//
+	// Intro[^1].
+	//
+	// [^1]: Note
+	// --- One
+	// # One
+	//
+	// First.
+	//
+	// ## Deeper
+	//
+	// ```
+	// ## not a heading
+	// ```
+	// --- Big (part 2)
+	// # Big (part 2)
+	//
+	// Second[^1].
+	//
+	// [^1]: Note
+}
+
+func ExampleMergeText() {
+	a := "# A\n\nFirst[^1].\n\n[^1]: Note of A\n"
+	b := "# B\n\nSecond[^1].\n\n## Sub\n\n[^1]: Note of B\n"
+	fmt.Print(keg.MergeText(a, b, `12`))
+
+	// Output:
+	// # A
+	//
+	// First[^1].
+	//
+	// ## B
+	//
+	// Second[^12-1].
+	//
+	// ### Sub
+	//
+	// [^1]: Note of A
+	// [^12-1]: Note of B
+}
+
+func ExampleRedirectLinks() {
+	text := "See [b](../12), [c](../120), and [d](keg:other/12).\n" +
+		"![fig](../12/fig.png) but not `[e](../12/e.md)`.\n"
+	fmt.Print(keg.RedirectLinks(text, 12, 3))
+
+	// Output:
+	// See [b](../3), [c](../120), and [d](keg:other/12).
+	// ![fig](../3/fig.png) but not `[e](../12/e.md)`.
+}
+
+func ExampleRebaseFileLinks() {
+	text := "# Part\n\n![fig](fig.png) and [doc](./doc.pdf?T) but not [n](../3),\n" +
+		"[web](https://example.com), or `[code](code.md)`.\n\n" +
+		"```\n[example](example.md)\n```\n"
+	fmt.Print(keg.RebaseFileLinks(text, 7))
+
+	// Output:
+	// # Part
+	//
+	// ![fig](../7/fig.png) and [doc](../7/doc.pdf?T) but not [n](../3),
+	// [web](https://example.com), or `[code](code.md)`.
+	//
+	// ```
+	// [example](example.md)
+	// ```
+}
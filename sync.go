@@ -0,0 +1,283 @@
+package keg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rwxrob/fs"
+	"github.com/rwxrob/term"
+)
+
+// Kinds of SyncError.
+const (
+	SyncNoRemote = `noremote` // no remote or upstream branch set up
+	SyncNetwork  = `network`  // remote could not be reached
+	SyncAuth     = `auth`     // remote refused credentials
+	SyncMerge    = `merge`    // node conflicts need manual resolution
+	SyncOther    = `other`    // anything else
+)
+
+// SyncError is returned by Sync (and GitPublisher) when git fails
+// talking to the remote repo or merging. Kind is one of the Sync*
+// constants. Nodes contains the IDs of nodes (and paths of any other
+// files) with conflicts that must be resolved manually (only for
+// SyncMerge) and Out contains the output from git.
+type SyncError struct {
+	Kind  string
+	Nodes []string
+	Out   string
+}
+
+// Error fulfills the error interface.
+func (e SyncError) Error() string {
+	switch e.Kind {
+	case SyncNoRemote:
+		return fmt.Sprintf(_NoRemoteRepo, term.Red, term.X)
+	case SyncNetwork:
+		return fmt.Sprintf(_SyncNetwork, lastLine(e.Out))
+	case SyncAuth:
+		return fmt.Sprintf(_SyncAuth, lastLine(e.Out))
+	case SyncMerge:
+		return fmt.Sprintf(_SyncMerge, strings.Join(e.Nodes, ` `))
+	}
+	return fmt.Sprintf(_SyncOther, lastLine(e.Out))
+}
+
+var (
+	syncAuthExp = regexp.MustCompile(`(?i)authentication failed|` +
+		`permission denied|could not read (username|password)|` +
+		`terminal prompts disabled|returned error: 40[13]|access denied`)
+	syncNetworkExp = regexp.MustCompile(`(?i)could not resolve host|` +
+		`connection (refused|timed out|reset)|network is unreachable|` +
+		`operation timed out|unable to access|` +
+		`could not read from remote repository`)
+	syncNoRemoteExp = regexp.MustCompile(`(?i)no tracking information|` +
+		`no remote repository specified|` +
+		`does not appear to be a git repository|no configured push destination`)
+	syncMergeExp = regexp.MustCompile(`(?m)^CONFLICT|Automatic merge failed|` +
+		`not possible because you have unmerged files|` +
+		`You have not concluded your merge`)
+)
+
+// SyncErrorFrom returns a SyncError with the Kind determined from the
+// output of a failed git command.
+func SyncErrorFrom(out string) SyncError {
+	e := SyncError{Kind: SyncOther, Out: out}
+	switch {
+	case syncMergeExp.MatchString(out):
+		e.Kind = SyncMerge
+	case syncAuthExp.MatchString(out):
+		e.Kind = SyncAuth
+	case syncNoRemoteExp.MatchString(out):
+		e.Kind = SyncNoRemote
+	case syncNetworkExp.MatchString(out):
+		e.Kind = SyncNetwork
+	}
+	return e
+}
+
+// Sync synchronizes the keg at kegpath with the remote of the git repo
+// containing it. Any pending changes are committed first (see
+// PendingChange) and then git pull is used to merge in the changes of
+// others. Conflicts in the dex directory are resolved automatically by
+// regenerating it with MakeDex, as are conflicts in the keg file that
+// only involve the updated line. Any remaining conflicts (usually in
+// node README.md files, but also any binary files within nodes, see
+// resolvedText) leave the merge in progress and return a
+// SyncError with the Nodes needing manual resolution, after which Sync
+// can be called again to finish. If push is true the merged result is
+// pushed. The paths (relative to kegpath) of any files resolved
+// automatically are returned.
+func Sync(kegpath string, push bool) ([]string, error) {
+	root, sub, err := GitRepo(kegpath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !gitMerging(root) {
+		if err := commitPending(kegpath, root, sub); err != nil {
+			return nil, err
+		}
+		if out, err := gitCombined(root, `pull`, `--no-rebase`, `--no-edit`); err != nil {
+			if e := SyncErrorFrom(out); e.Kind != SyncMerge {
+				return nil, e
+			}
+		}
+	}
+
+	resolved, err := resolveConflicts(kegpath, root, sub)
+	if err != nil {
+		return resolved, err
+	}
+
+	if gitMerging(root) {
+		if out, err := gitCombined(root, `commit`, `--no-edit`); err != nil {
+			return resolved, SyncErrorFrom(out)
+		}
+	}
+
+	if push {
+		if out, err := gitCombined(root, `push`); err != nil {
+			return resolved, SyncErrorFrom(out)
+		}
+	}
+	return resolved, nil
+}
+
+// commitPending commits any pending changes within the keg.
+func commitPending(kegpath, root, sub string) error {
+	changed, err := Pending(kegpath)
+	if err != nil || len(changed) == 0 {
+		return err
+	}
+	msg, err := PendingChange(kegpath, changed).Message(
+		ReadPublishConf(kegpath).Message,
+	)
+	if err != nil {
+		return err
+	}
+	if out, err := gitCombined(root, gitAdd(sub)...); err != nil {
+		return SyncErrorFrom(out)
+	}
+	if out, err := gitCombined(root, `commit`, `-m`, msg, `--`, sub); err != nil {
+		return SyncErrorFrom(out)
+	}
+	return nil
+}
+
+// resolveConflicts resolves any conflicts that can be resolved
+// automatically and returns a SyncError for any that cannot. Conflicts
+// in the dex and keg file are only resolved (and returned) once there
+// are no others since the dex cannot be regenerated until then.
+func resolveConflicts(kegpath, root, sub string) ([]string, error) {
+	out, err := gitOut(root, `diff`, `--name-only`, `--diff-filter=U`)
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.ToSlash(sub) + `/`
+	if sub == `.` {
+		prefix = ""
+	}
+	var resolved, redex, nodes, other []string
+	seen := map[string]bool{}
+	for _, path := range strings.Split(strings.TrimSpace(out), "\n") {
+		if path == "" {
+			continue
+		}
+		if !strings.HasPrefix(path, prefix) {
+			other = append(other, path)
+			continue
+		}
+		rel := strings.TrimPrefix(path, prefix)
+		id, _, _ := strings.Cut(rel, `/`)
+		switch {
+		case id == `dex`:
+			redex = append(redex, rel)
+		case rel == `keg` && onlyUpdatedDiffers(root, path):
+			if _, err := gitOut(root, `checkout`, `--theirs`, `--`, path); err != nil {
+				return nil, err
+			}
+			redex = append(redex, rel)
+		case fs.NameIsInt(id):
+			if resolvedText(filepath.Join(root, path)) {
+				if _, err := gitOut(root, `add`, `-A`, `--`, path); err != nil {
+					return nil, err
+				}
+				resolved = append(resolved, rel)
+				continue
+			}
+			if !seen[id] {
+				seen[id] = true
+				nodes = append(nodes, id)
+			}
+		default:
+			other = append(other, path)
+		}
+	}
+	if len(nodes) > 0 || len(other) > 0 {
+		return resolved, SyncError{
+			Kind:  SyncMerge,
+			Nodes: append(nodes, other...),
+			Out:   out,
+		}
+	}
+	if len(redex) == 0 {
+		return resolved, nil
+	}
+	if err := MakeDex(kegpath); err != nil {
+		return resolved, err
+	}
+	if out, err := gitCombined(root, gitAdd(sub)...); err != nil {
+		return resolved, SyncErrorFrom(out)
+	}
+	return append(resolved, redex...), nil
+}
+
+// onlyUpdatedDiffers returns true if our version and their version of
+// the conflicted file at path (relative to root) are identical except
+// for the updated line.
+func onlyUpdatedDiffers(root, path string) bool {
+	ours, err := gitOut(root, `show`, `:2:`+path)
+	if err != nil {
+		return false
+	}
+	theirs, err := gitOut(root, `show`, `:3:`+path)
+	if err != nil {
+		return false
+	}
+	return updatedLineExp.ReplaceAllString(ours, "") ==
+		updatedLineExp.ReplaceAllString(theirs, "")
+}
+
+var updatedLineExp = regexp.MustCompile(`(?m)^updated:.*$`)
+
+var conflictMarkerExp = regexp.MustCompile(`(?m)^(<<<<<<<|>>>>>>>) `)
+
+// resolvedText returns true if the conflicted file at path has been
+// resolved by hand: it exists, is text (valid UTF-8 without any NUL
+// bytes), and no longer contains git conflict markers. Git leaves one
+// of the versions of a conflicted binary file (an image, for example)
+// without any markers so there is no telling if it has been resolved.
+// Those (and files deleted on one side) must be resolved with git
+// itself (git checkout --ours or --theirs and git add).
+func resolvedText(path string) bool {
+	buf, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(buf, 0) >= 0 || !utf8.Valid(buf) {
+		return false
+	}
+	return !conflictMarkerExp.Match(buf)
+}
+
+// gitMerging returns true if the repo at root has a merge in progress.
+func gitMerging(root string) bool {
+	out, err := gitOut(root, `rev-parse`, `--git-path`, `MERGE_HEAD`)
+	if err != nil {
+		return false
+	}
+	path := strings.TrimSpace(out)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// gitCombined runs git from within dir and returns its combined
+// standard output and error.
+func gitCombined(dir string, args ...string) (string, error) {
+	cmd := exec.Command(`git`, append([]string{`-C`, dir}, args...)...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// lastLine returns the last non-blank line of the output.
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
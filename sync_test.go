@@ -0,0 +1,121 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleSyncErrorFrom() {
+	fmt.Println(keg.SyncErrorFrom(
+		"fatal: unable to access 'https://x/': Could not resolve host: x",
+	).Kind)
+	fmt.Println(keg.SyncErrorFrom(
+		"git@github.com: Permission denied (publickey).\n" +
+			"fatal: Could not read from remote repository.",
+	).Kind)
+	fmt.Println(keg.SyncErrorFrom(
+		"CONFLICT (content): Merge conflict in docs/6/README.md\n" +
+			"Automatic merge failed; fix conflicts and then commit the result.",
+	).Kind)
+	fmt.Println(keg.SyncErrorFrom(
+		"There is no tracking information for the current branch.",
+	).Kind)
+	// Output:
+	// network
+	// auth
+	// merge
+	// noremote
+}
+
+func ExampleSync() {
+	dir, _ := os.MkdirTemp("", "kegsync")
+	defer os.RemoveAll(dir)
+	git := func(in string, args ...string) {
+		cmd := exec.Command(`git`, append([]string{`-C`, in}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			fmt.Println(err, string(out))
+		}
+	}
+	node := func(kegdir string, id int, title string) {
+		path := filepath.Join(kegdir, fmt.Sprint(id), `README.md`)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("# "+title+"\n"), 0644)
+		t := time.Date(2023, 1, id, 0, 0, 0, 0, time.UTC)
+		os.Chtimes(path, t, t)
+		os.Chtimes(filepath.Dir(path), t, t)
+		keg.DexUpdate(kegdir, &keg.DexEntry{N: id})
+	}
+
+	origin := filepath.Join(dir, `origin.git`)
+	git(dir, `init`, `-q`, `--bare`, origin)
+	ours, theirs := filepath.Join(dir, `ours`), filepath.Join(dir, `theirs`)
+	clone := func(path string) {
+		git(dir, `clone`, `-q`, origin, path)
+		git(path, `config`, `user.email`, `keg@example.com`)
+		git(path, `config`, `user.name`, `keg`)
+	}
+	clone(ours)
+	os.WriteFile(filepath.Join(ours, `keg`), []byte("updated:\n"), 0644)
+	node(ours, 1, `First`)
+	git(ours, `add`, `-A`)
+	git(ours, `commit`, `-qm`, `init`)
+	git(ours, `push`, `-q`, `-u`, `origin`, `HEAD`)
+	clone(theirs)
+
+	// both add a node changing the dex and keg files
+	node(theirs, 2, `Theirs`)
+	git(theirs, `add`, `-A`)
+	git(theirs, `commit`, `-qm`, `theirs`)
+	git(theirs, `push`, `-q`, `origin`, `HEAD`)
+	node(ours, 3, `Ours`)
+	os.WriteFile(filepath.Join(ours, `1`, `fig.png`), []byte("\x89PNG\x00ours"), 0644)
+
+	resolved, err := keg.Sync(ours, false)
+	fmt.Println(resolved, err)
+	changes, _ := os.ReadFile(filepath.Join(ours, `dex`, `changes.md`))
+	_, merged, _, err := keg.ParseChanges(changes)
+	fmt.Println(strings.Contains(string(changes), `<<<<<<<`), err,
+		merged.Lookup(2) != nil, merged.Lookup(3) != nil)
+
+	// a binary file in a node conflicts too and is never resolved (nor
+	// is the dex until it is)
+	os.WriteFile(filepath.Join(theirs, `1`, `fig.png`), []byte("\x89PNG\x00theirs"), 0644)
+	node(theirs, 4, `Later`)
+	git(theirs, `add`, `-A`)
+	git(theirs, `commit`, `-qm`, `fig`)
+	git(theirs, `push`, `-q`, `origin`, `HEAD`)
+	os.WriteFile(filepath.Join(ours, `1`, `fig.png`), []byte("\x89PNG\x00mine"), 0644)
+	node(ours, 5, `Mine`)
+
+	resolved, err = keg.Sync(ours, false)
+	fmt.Println(resolved, err)
+
+	git(ours, `checkout`, `--ours`, `--`, `1/fig.png`)
+	git(ours, `add`, `1/fig.png`)
+	resolved, err = keg.Sync(ours, false)
+	fmt.Println(resolved, err)
+
+	dex, _ := keg.ReadDex(ours)
+	fmt.Println(len(*dex))
+
+	// the lock file of another writer is never committed
+	os.WriteFile(filepath.Join(ours, keg.LockFile), nil, 0644)
+	os.WriteFile(filepath.Join(ours, `5`, `README.md`), []byte("# Mine\n\nMore\n"), 0644)
+	resolved, err = keg.Sync(ours, false)
+	tracked, _ := exec.Command(`git`, `-C`, ours, `ls-files`, keg.LockFile).Output()
+	fmt.Printf("%v %v %q\n", resolved, err, tracked)
+
+	// Output:
+	// [dex/changes.md dex/links dex/nodes.tsv keg] <nil>
+	// false <nil> true true
+	// [] merge conflicts must be resolved in: 1 (then sync again)
+	// [dex/changes.md dex/links dex/nodes.tsv keg] <nil>
+	// 5
+	// [] <nil> ""
+}
@@ -0,0 +1,113 @@
+package keg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplatesDir is the name of the directory (within a keg or the
+// configuration directory) containing node templates. Each template is
+// a file named after the template with a .md suffix (meeting.md, for
+// example).
+const TemplatesDir = `templates`
+
+// SampleTemplate is the name of the built-in template that writes
+// SampleNodeReadme (see WriteSample). A template file with the same
+// name takes its place.
+const SampleTemplate = `sample`
+
+// TemplateData is the data available to node templates. For example,
+// {{.ID}} is replaced with the ID of the new node.
+type TemplateData struct {
+	ID    int       // node ID
+	Keg   string    // name of the keg
+	Title string    // title (if given when created)
+	Time  time.Time // time of creation in UTC
+}
+
+// Date returns the date of creation (2006-01-02).
+func (d TemplateData) Date() string { return d.Time.Format(`2006-01-02`) }
+
+// Updated returns the time of creation in the same form as the dex
+// (see IsoDateFmt).
+func (d TemplateData) Updated() string { return d.Time.Format(IsoDateFmt) }
+
+// Templates returns the names of all the node templates found within
+// the directories passed (usually the TemplatesDir of the keg and then
+// that of the configuration directory) sorted and without duplicates.
+// Directories that do not exist are quietly skipped.
+func Templates(dirs ...string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := strings.TrimSuffix(e.Name(), `.md`)
+			if e.IsDir() || name == e.Name() || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindTemplate returns the path to the template file for name from the
+// first of the directories passed that has one.
+func FindTemplate(name string, dirs ...string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf(_TemplateNotFound, name)
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name+`.md`)
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf(_TemplateNotFound, name)
+}
+
+// ExecTemplate executes the node template text with the data passed.
+// In addition to the fields and methods of TemplateData the following
+// functions are available:
+//
+//	{{date "Jan 2, 2006"}}  time of creation in any Go time layout
+//	{{prompt "Attendees"}}  value from prompt (same answer every time)
+//
+// If prompt is nil the prompt function returns empty strings.
+func ExecTemplate(text string, data TemplateData, prompt func(q string) (string, error)) (string, error) {
+	answers := map[string]string{}
+	funcs := template.FuncMap{
+		`date`: func(layout string) string { return data.Time.Format(layout) },
+		`prompt`: func(q string) (string, error) {
+			if a, has := answers[q]; has || prompt == nil {
+				return a, nil
+			}
+			a, err := prompt(q)
+			if err != nil {
+				return "", err
+			}
+			answers[q] = a
+			return a, nil
+		},
+	}
+	t, err := template.New(`node`).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
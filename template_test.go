@@ -0,0 +1,57 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleExecTemplate() {
+	data := keg.TemplateData{
+		ID:   42,
+		Keg:  `zet`,
+		Time: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	prompt := func(q string) (string, error) {
+		fmt.Println("asked:", q)
+		return `Planning`, nil
+	}
+	out, err := keg.ExecTemplate(
+		"# {{prompt \"Topic\"}} {{.Date}}\n\n"+
+			"{{.ID}} in {{.Keg}} at {{.Updated}} ({{date \"Jan 2\"}}): {{prompt \"Topic\"}}\n",
+		data, prompt)
+	fmt.Print(out)
+	fmt.Println(err)
+
+	// Output:
+	// asked: Topic
+	// # Planning 2023-01-02
+	//
+	// 42 in zet at 2023-01-02 03:04:05Z (Jan 2): Planning
+	// <nil>
+}
+
+func ExampleTemplates() {
+	kegdir, _ := os.MkdirTemp("", "kegtmpl")
+	defer os.RemoveAll(kegdir)
+	confdir, _ := os.MkdirTemp("", "kegtmpl")
+	defer os.RemoveAll(confdir)
+	os.WriteFile(filepath.Join(kegdir, `meeting.md`), []byte(`keg`), 0644)
+	os.WriteFile(filepath.Join(kegdir, `notes.txt`), []byte(`no`), 0644)
+	os.WriteFile(filepath.Join(confdir, `meeting.md`), []byte(`conf`), 0644)
+	os.WriteFile(filepath.Join(confdir, `adr.md`), []byte(`conf`), 0644)
+
+	fmt.Println(keg.Templates(kegdir, confdir, `/no/such/dir`))
+	path, _ := keg.FindTemplate(`meeting`, kegdir, confdir)
+	fmt.Println(path == filepath.Join(kegdir, `meeting.md`))
+	_, err := keg.FindTemplate(`runbook`, kegdir, confdir)
+	fmt.Println(err)
+
+	// Output:
+	// [adr meeting]
+	// true
+	// node template not found: "runbook"
+}
@@ -27,6 +27,9 @@ var _index string
 //go:embed text/en/index-update.md
 var _index_update string
 
+//go:embed text/en/index-verify.md
+var _index_verify string
+
 //go:embed text/en/last.md
 var _last string
 
@@ -57,6 +60,33 @@ var _view string
 //go:embed text/en/create.md
 var _create string
 
+//go:embed text/en/today.md
+var _today string
+
+//go:embed text/en/append.md
+var _append string
+
+//go:embed text/en/split.md
+var _split string
+
+//go:embed text/en/merge.md
+var _merge string
+
+//go:embed text/en/attach.md
+var _attach string
+
+//go:embed text/en/assets.md
+var _assets string
+
+//go:embed text/en/check.md
+var _check string
+
+//go:embed text/en/check-assets.md
+var _check_assets string
+
+//go:embed text/en/dupes.md
+var _dupes string
+
 //go:embed text/en/keg
 var _kegyaml string
 
@@ -66,21 +96,116 @@ var _zero_node string
 //go:embed text/en/link.md
 var _link string
 
+//go:embed text/en/backlinks.md
+var _backlinks string
+
 //go:embed text/en/tag.md
 var _tag string
 
+//go:embed text/en/publish.md
+var _publish string
+
+//go:embed text/en/sync.md
+var _sync string
+
+//go:embed text/en/watch.md
+var _watch string
+
+//go:embed text/en/map.md
+var _map string
+
+//go:embed text/en/map-list.md
+var _map_list string
+
+//go:embed text/en/map-add.md
+var _map_add string
+
+//go:embed text/en/map-rm.md
+var _map_rm string
+
+//go:embed text/en/use.md
+var _use string
+
+//go:embed text/en/all.md
+var _all string
+
+//go:embed text/en/all-titles.md
+var _all_titles string
+
+//go:embed text/en/all-grep.md
+var _all_grep string
+
+//go:embed text/en/all-changes.md
+var _all_changes string
+
+//go:embed text/en/send.md
+var _send string
+
 const (
-	_NoKegsFound     = `no kegs found`
-	_NodeNotFound    = `node not found: %v`
-	_InvalidNodeID   = `invalid node id: %q`
-	_FileNotFound    = `file not found: %v`
-	_ChooseTitleFail = `unable to choose a title`
-	_AbsPathFail     = `unable to determine absolute path to current directory`
-	_BadChangesLine  = `bad line in changes.md: %v`
-	_NoRemoteRepo    = `%vNo remote repo has been setup.%v First create it and git push to it.`
-	_NotDirNotExist  = `not a directory or does not exist: %v`
-	_CantGetNextNode = `could not determine next node id: %v`
-	_NotInKegFile    = `keg file does not contain: %v`
-	_StringHasNo     = `string does not contain: %v`
-	_InvalidTagLine  = `invalid tag line: %v`
+	_NoKegsFound      = `no kegs found`
+	_NodeNotFound     = `node not found: %v`
+	_InvalidNodeID    = `invalid node id: %q`
+	_FileNotFound     = `file not found: %v`
+	_ChooseTitleFail  = `unable to choose a title`
+	_AbsPathFail      = `unable to determine absolute path to current directory`
+	_BadChangesLine   = `bad line in changes.md: %v`
+	_BadChangesEntry  = `bad entry in changes.md at line %v, column %v (expecting %v)`
+	_ChangesConflict  = `conflict marker in changes.md at line %v`
+	_NoRemoteRepo     = `%vNo remote repo has been setup.%v First create it and git push to it.`
+	_NotDirNotExist   = `not a directory or does not exist: %v`
+	_CantGetNextNode  = `could not determine next node id: %v`
+	_NotInKegFile     = `keg file does not contain: %v`
+	_StringHasNo      = `string does not contain: %v`
+	_InvalidTagLine   = `invalid tag line: %v`
+	_BadPublishMode   = `invalid publish mode (want none, commit, or push): %q`
+	_BadPublishTarget = `unknown publish target type: %q`
+	_PublishNoPath    = `publish target %v requires a path`
+	_PublishInsideKeg = `publish target path must be outside of keg: %v`
+	_PublishAroundKeg = `publish target path must not contain keg: %v`
+	_PublishNotMirror = `publish target is not empty and has no %v file (not a keg mirror): %v`
+	_SyncNetwork      = `unable to reach remote repo (network): %v`
+	_SyncAuth         = `remote repo refused access (authentication): %v`
+	_SyncMerge        = `merge conflicts must be resolved in: %v (then sync again)`
+	_SyncOther        = `git failed: %v`
+	_KegLocked        = `keg locked by another process (%v) since %v (remove %v if stale)`
+	_NotGitRepo       = `not within a git repo: %v`
+	_BadArchiveType   = `unsupported archive type (want .zip, .tar, .tar.gz, or .tgz): %v`
+	_BadNodesLine     = `bad line in nodes.tsv: %v`
+	_BadLinksLine     = `bad line in dex/links: %v`
+	_DriftNotIn       = `node %v missing from %v`
+	_DriftNoNode      = `%v lists %v but node directory does not exist`
+	_DriftEmpty       = `node %v is empty`
+	_DriftTitle       = `node %v dex title %q differs from README.md %q`
+	_DriftMismatch    = `node %v differs between changes.md and nodes.tsv`
+	_DriftTag         = `tag %v lists %v but node directory does not exist`
+	_DriftUpdated     = `keg file updated (%v) differs from changes.md (%v)`
+	_BadInterval      = `invalid interval (want duration such as 10m): %v`
+	_BadKegName       = `invalid keg name (no dots, slashes, or spaces): %q`
+	_NotKegDir        = `no keg (or docs/keg) file found in: %v`
+	_TemplateNotFound = `node template not found: %q`
+	_MissingArg       = `missing value for %v`
+	_UnknownArg       = `unknown argument: %q`
+	_NoNodeTitle      = `node has no title (begin with "# TITLE" or use --title)`
+	_SplitPart        = `%v (part %v)`
+	_NothingToSplit   = `no second-level headings (##) or separators (---) to split node %v at`
+	_MergeSame        = `cannot merge node into itself: %v`
+	_MergeConflict    = `cannot merge, file %v already exists in node %v`
+	_MergeKegs        = `cannot merge nodes from different kegs: %v %v`
+	_NotAFile         = `not a regular file: %v`
+	_BadSize          = `invalid size (want bytes or with K, M, or G): %q`
+	_AssetTooLarge    = `node %v file %v is too large (%v, max %v)`
+	_AssetNotAllowed  = `node %v file %v extension not allowed (%v)`
+	_AssetProblems    = `%v asset problems found`
+	_AssetsBlocked    = `%v asset problems found, not publishing (see "keg check assets")`
+	_DupeTitle        = `node %v has the same (or nearly the same) title as %v: %v`
+	_KegNotMapped     = `keg not found in map: %v`
+	_NoKegName        = `keg name (or path) must not be empty`
+	_NoNodesFound     = `no nodes found in keg: %v`
+	_MapNoKeg         = `(no keg found)`
+	_InvalidCount     = `invalid count: %v`
+	_BadSendMode      = `invalid send mode (want move, copy, or stub): %q`
+	_SendSameKeg      = `cannot send node to the keg it is in: %v`
+	_SendStub         = "# %v\n\nMoved to [%v](%v).\n"
+	_BadFormat        = `invalid format (want json, tsv, md, or pretty): %q`
+	_DexDrift         = `dex out of sync (%v problems), run "keg index update" to fix`
 )
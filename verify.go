@@ -0,0 +1,122 @@
+package keg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/rwxrob/fs/dir"
+	"github.com/rwxrob/keg/kegml"
+)
+
+// VerifyDex checks that the dex files (changes.md, nodes.tsv, and tags)
+// of the keg at kegpath agree with each other, with the node
+// directories, and with the updated field of the keg file and returns
+// a description of every drift found (none if the dex is in sync).
+// Node titles are compared with the first line of each README.md but
+// update times are not compared with the files themselves since they
+// are not preserved by git and most other copying. An error is only
+// returned if a dex file cannot be read or parsed. Use MakeDex to
+// correct any drift.
+func VerifyDex(kegpath string) ([]string, error) {
+	buf, err := os.ReadFile(filepath.Join(kegpath, `dex`, `changes.md`))
+	if err != nil {
+		return nil, err
+	}
+	changes, err := ParseDex(buf)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := ReadDexTSV(kegpath)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := ReadTags(kegpath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var drift []string
+	inchanges := dexByID(*changes)
+	innodes := dexByID(*nodes)
+
+	dirs, _, _ := NodePaths(kegpath)
+	ondisk := map[int]bool{}
+	for _, d := range dirs {
+		id, err := strconv.Atoi(d.Info.Name())
+		if err != nil {
+			continue
+		}
+		ondisk[id] = true
+		if dir.IsEmpty(d.Path) {
+			drift = append(drift, fmt.Sprintf(_DriftEmpty, id))
+		}
+		c, n := inchanges[id], innodes[id]
+		if c == nil {
+			drift = append(drift, fmt.Sprintf(_DriftNotIn, id, `changes.md`))
+		}
+		if n == nil {
+			drift = append(drift, fmt.Sprintf(_DriftNotIn, id, `nodes.tsv`))
+		}
+		title, _ := kegml.ReadTitle(d.Path)
+		e := c
+		if e == nil {
+			e = n
+		}
+		if e != nil && e.T != title {
+			drift = append(drift, fmt.Sprintf(_DriftTitle, id, e.T, title))
+		}
+		if c != nil && n != nil && (c.T != n.T || !c.U.Equal(n.U)) {
+			drift = append(drift, fmt.Sprintf(_DriftMismatch, id))
+		}
+	}
+
+	for _, f := range []struct {
+		name string
+		dex  Dex
+	}{{`changes.md`, *changes}, {`nodes.tsv`, *nodes}} {
+		for _, e := range f.dex.ByID() {
+			if !ondisk[e.N] {
+				drift = append(drift, fmt.Sprintf(_DriftNoNode, f.name, e.N))
+			}
+		}
+	}
+
+	names := make([]string, 0, len(tags))
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+	for _, tag := range names {
+		for _, id := range tags[tag] {
+			if n, err := strconv.Atoi(id); err != nil || !ondisk[n] {
+				drift = append(drift, fmt.Sprintf(_DriftTag, tag, id))
+			}
+		}
+	}
+
+	if len(*changes) > 0 {
+		latest := changes.ByChanges()[0].U.Format(IsoDateFmt)
+		info, err := ReadKegInfo(kegpath)
+		if err != nil {
+			return nil, err
+		}
+		if info.Updated != latest {
+			drift = append(drift, fmt.Sprintf(_DriftUpdated, info.Updated, latest))
+		}
+	}
+
+	return drift, nil
+}
+
+// dexByID returns a map of the entries of the dex by ID.
+func dexByID(dex Dex) map[int]*DexEntry {
+	m := map[int]*DexEntry{}
+	for _, e := range dex {
+		m[e.N] = e
+	}
+	return m
+}
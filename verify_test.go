@@ -0,0 +1,38 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleVerifyDex() {
+	dir, _ := os.MkdirTemp("", "kegverify")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, `keg`), []byte("updated:\n"), 0644)
+	for _, n := range []string{`1`, `2`} {
+		os.Mkdir(filepath.Join(dir, n), 0700)
+		os.WriteFile(filepath.Join(dir, n, `README.md`), []byte("# Node "+n+"\n"), 0644)
+	}
+	keg.MakeDex(dir)
+	keg.Tag(dir, `2`, `foo`)
+
+	drift, err := keg.VerifyDex(dir)
+	fmt.Println(drift, err)
+
+	os.RemoveAll(filepath.Join(dir, `2`))
+	os.WriteFile(filepath.Join(dir, `1`, `README.md`), []byte("# Changed\n"), 0644)
+	drift, err = keg.VerifyDex(dir)
+	for _, d := range drift {
+		fmt.Println(d)
+	}
+
+	// Output:
+	// [] <nil>
+	// node 1 dex title "Node 1" differs from README.md "Changed"
+	// changes.md lists 2 but node directory does not exist
+	// nodes.tsv lists 2 but node directory does not exist
+	// tag foo lists 2 but node directory does not exist
+}
@@ -0,0 +1,157 @@
+package keg
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rwxrob/fs/dir"
+)
+
+// WatchDelay is how long Watch waits after the last change to a node
+// before updating the dex so that bursts of changes (editors often
+// write several files when saving) result in a single update.
+var WatchDelay = 500 * time.Millisecond
+
+// Watch watches the node directories of the keg at kegpath for changes
+// made outside of the keg command (from an IDE, for example) and keeps
+// the dex in sync until done is closed. Changed nodes are updated with
+// DexUpdate and removed (or emptied) nodes with DexRemove after no
+// more changes have been seen for WatchDelay. Hidden files (starting
+// with a dot) and editor backup files (ending with ~) are ignored as
+// are the dex and any other non-node directories. If updated is not nil
+// it is called with the DexEntry of every node updated.
+//
+// If every is greater than zero, publish (usually a call to Publish
+// with force) is called that often to publish whatever has changed.
+// Errors from updating or publishing are logged and watching
+// continues. An error is only returned if watching cannot be started.
+func Watch(kegpath string, done <-chan struct{}, updated func(*DexEntry), publish func() error, every time.Duration) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := w.Add(kegpath); err != nil {
+		return err
+	}
+	dirs, _, _ := NodePaths(kegpath)
+	for _, d := range dirs {
+		watchTree(w, d.Path)
+	}
+
+	var tick <-chan time.Time
+	if every > 0 && publish != nil {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	changed := map[int]bool{}
+	timer := time.NewTimer(WatchDelay)
+	timer.Stop()
+
+	for {
+		select {
+
+		case <-done:
+			return nil
+
+		case e, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			id, ok := watchedNode(kegpath, e.Name)
+			if !ok {
+				continue
+			}
+			if e.Op&fsnotify.Create != 0 {
+				watchTree(w, e.Name)
+			}
+			changed[id] = true
+			timer.Reset(WatchDelay)
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			log.Println(err)
+
+		case <-timer.C:
+			for id := range changed {
+				entry, err := syncNode(kegpath, id)
+				if err != nil {
+					log.Println(err)
+				} else if entry != nil && updated != nil {
+					updated(entry)
+				}
+				delete(changed, id)
+			}
+
+		case <-tick:
+			if err := publish(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// watchTree adds the directory at path and every directory within it
+// to the watcher (since inotify does not watch recursively). Anything
+// that is not a directory is quietly ignored.
+func watchTree(w *fsnotify.Watcher, path string) {
+	filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), `.`) && p != path {
+				return filepath.SkipDir
+			}
+			w.Add(p)
+		}
+		return nil
+	})
+}
+
+// watchedNode returns the node ID for a path within the keg at kegpath
+// and false if the path is not within a node or should be ignored.
+func watchedNode(kegpath, path string) (int, bool) {
+	rel, err := filepath.Rel(kegpath, path)
+	if err != nil {
+		return 0, false
+	}
+	name := filepath.Base(rel)
+	if strings.HasPrefix(name, `.`) || strings.HasSuffix(name, `~`) {
+		return 0, false
+	}
+	first, _, _ := strings.Cut(filepath.ToSlash(rel), `/`)
+	id, err := strconv.Atoi(first)
+	if err != nil || id < 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// syncNode updates (or removes) the dex entry for the node with the
+// given id to match its node directory returning the entry if it was
+// updated (nil if removed).
+func syncNode(kegpath string, id int) (*DexEntry, error) {
+	entry := &DexEntry{N: id}
+	path := filepath.Join(kegpath, entry.ID())
+	if _, err := os.Stat(path); err != nil || dir.IsEmpty(path) {
+		dex, err := ReadDex(kegpath)
+		if err != nil || dex.Lookup(id) == nil {
+			return nil, err
+		}
+		log.Println("❌", path)
+		return nil, DexRemove(kegpath, entry)
+	}
+	log.Println("✔", path)
+	return entry, DexUpdate(kegpath, entry)
+}
@@ -0,0 +1,75 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleWatch() {
+	dir, _ := os.MkdirTemp("", "kegwatch")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, `keg`), []byte("updated:\n"), 0644)
+	os.Mkdir(filepath.Join(dir, `1`), 0700)
+	os.WriteFile(filepath.Join(dir, `1`, `README.md`), []byte("# One\n"), 0644)
+	keg.MakeDex(dir)
+
+	defer func(d time.Duration) { keg.WatchDelay = d }(keg.WatchDelay)
+	keg.WatchDelay = 50 * time.Millisecond
+
+	updated := make(chan int, 10)
+	published := make(chan bool, 1)
+	publish := func() error {
+		select {
+		case published <- true:
+		default:
+		}
+		return nil
+	}
+	done := make(chan struct{})
+	go keg.Watch(dir, done, func(e *keg.DexEntry) { updated <- e.N },
+		publish, 20*time.Millisecond)
+	defer close(done)
+	time.Sleep(100 * time.Millisecond) // let the watcher start
+
+	titles := func() []string {
+		dex, _ := keg.ReadDex(dir)
+		var t []string
+		for _, e := range *dex {
+			t = append(t, fmt.Sprint(e.N, ` `, e.T))
+		}
+		return t
+	}
+
+	// several quick writes result in a single update (debounce)
+	readme := filepath.Join(dir, `1`, `README.md`)
+	os.WriteFile(readme, []byte("# Once\n"), 0644)
+	os.WriteFile(readme, []byte("# Twice\n"), 0644)
+	fmt.Println(<-updated)
+	time.Sleep(4 * keg.WatchDelay)
+	fmt.Println(len(updated), titles())
+
+	// new nodes are added
+	os.Mkdir(filepath.Join(dir, `2`), 0700)
+	os.WriteFile(filepath.Join(dir, `2`, `README.md`), []byte("# Two\n"), 0644)
+	fmt.Println(<-updated, titles())
+
+	// removed nodes are dropped from the dex
+	os.RemoveAll(filepath.Join(dir, `1`))
+	for i := 0; i < 50 && len(titles()) != 1; i++ {
+		time.Sleep(keg.WatchDelay)
+	}
+	fmt.Println(titles())
+
+	fmt.Println(<-published)
+
+	// Output:
+	// 1
+	// 0 [1 Twice]
+	// 2 [2 Two 1 Twice]
+	// [2 Two]
+	// true
+}